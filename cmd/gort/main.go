@@ -0,0 +1,65 @@
+// Command gort writes the index used by gort.NewIndexRT.
+//
+//	go build -o app . && gort index app
+//
+// writes app.gortidx next to the binary, ship it with the binary and load it
+// with gort.NewIndexRT("app.gortidx").
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lsg2020/gort"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: gort index [-o file] binary\n")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "index" {
+		usage()
+	}
+
+	flags := flag.NewFlagSet("index", flag.ExitOnError)
+	out := flags.String("o", "", "output file, defaults to the binary path with a .gortidx suffix")
+	flags.Usage = usage
+	flags.Parse(os.Args[2:])
+	if flags.NArg() != 1 {
+		usage()
+	}
+
+	if err := writeIndex(flags.Arg(0), *out); err != nil {
+		fmt.Fprintf(os.Stderr, "gort index: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func writeIndex(binary, out string) error {
+	if out == "" {
+		out = binary + ".gortidx"
+	}
+	index, err := gort.BuildIndex(binary)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := index.Encode(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
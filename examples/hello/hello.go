@@ -47,6 +47,15 @@ func main() {
 	}
 	log.Printf("load  main.testGlobal %#v", rGlobal.Interface())
 
+	// test unexported field
+	rStr, err := gort.FieldByPath(rGlobal, "str")
+	if err != nil {
+		log.Fatalf("load field err %s\n", err)
+		return
+	}
+	rStr.SetString("test global patched")
+	log.Printf("patch main.testGlobal.str %#v", testGlobal)
+
 	// test func
 	fmt.Printf("test call fmt.Printf\n")
 
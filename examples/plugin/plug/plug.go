@@ -0,0 +1,23 @@
+// Command plug is the plugin loaded by the plugin test.
+package main
+
+import "github.com/lsg2020/gort/examples/plugin/shared"
+
+type settings struct {
+	level int
+	name  string
+}
+
+var Knob = 5
+
+var conf = &settings{level: 2, name: "plug"}
+
+func GetKnob() int { return Knob }
+
+func Level() int { return conf.level }
+
+func SetLevel(level int) { conf.level = level }
+
+func SharedCounter() int { return shared.Counter }
+
+func main() {}
@@ -0,0 +1,156 @@
+// Package plugin_test builds the plugin in ./plug, loads it and checks that the
+// globals of the plugin and of the host are read and written through gort at
+// the addresses the plugin uses.
+package plugin_test
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"plugin"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/lsg2020/gort"
+	"github.com/lsg2020/gort/examples/plugin/shared"
+	"github.com/lsg2020/gort/internal/testdwarf"
+)
+
+const plugPkg = "github.com/lsg2020/gort/examples/plugin/plug"
+
+func TestMain(m *testing.M) {
+	testdwarf.Main(m)
+}
+
+func TestPluginGlobals(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("plugins are not supported on %s", runtime.GOOS)
+	}
+	if out, err := exec.Command("go", "env", "CGO_ENABLED").Output(); err != nil || strings.TrimSpace(string(out)) != "1" {
+		t.Skip("plugins need cgo")
+	}
+
+	// build the plugin with the flags of the test so the shared packages match
+	plugPath := filepath.Join(t.TempDir(), "plug.so")
+	args := []string{"build", "-buildmode=plugin", "-o", plugPath}
+	if testdwarf.RaceEnabled {
+		args = append(args, "-race")
+	}
+	if out, err := exec.Command("go", append(args, "./plug")...).CombinedOutput(); err != nil {
+		t.Fatalf("build plugin err %s\n%s", err, out)
+	}
+	p, err := plugin.Open(plugPath)
+	if err != nil {
+		if strings.Contains(err.Error(), "different version of package") {
+			t.Skipf("plugin built with other flags than the test: %s", err)
+		}
+		t.Fatalf("open plugin err %s", err)
+	}
+
+	rt, err := gort.NewDwarfRT("")
+	if err != nil {
+		t.Fatalf("load dwarf err %s", err)
+	}
+	libAddr, err := findPlugin(rt, plugPath)
+	if err != nil {
+		t.Fatalf("search plugin err %s", err)
+	}
+	if err := rt.AddImage(plugPath, libAddr); err != nil {
+		t.Fatalf("add image err %s", err)
+	}
+
+	// the plugin global is found by its qualified and unqualified name, at the address the plugin uses
+	sym, err := p.Lookup("Knob")
+	if err != nil {
+		t.Fatalf("lookup Knob err %s", err)
+	}
+	knob, err := rt.FindGlobal("plug.so:" + plugPkg + ".Knob")
+	if err != nil {
+		t.Fatalf("find plugin global err %s", err)
+	}
+	if knob.Addr().Pointer() != reflect.ValueOf(sym).Pointer() {
+		t.Fatalf("plugin global at %#x, plugin uses %#x", knob.Addr().Pointer(), reflect.ValueOf(sym).Pointer())
+	}
+	if unqualified, err := rt.FindGlobal(plugPkg + ".Knob"); err != nil || unqualified.Addr().Pointer() != knob.Addr().Pointer() {
+		t.Fatalf("find unqualified plugin global err %v", err)
+	}
+	listed := false
+	rt.ForeachGlobal(func(name string, v reflect.Value) {
+		listed = listed || name == "plug.so:"+plugPkg+".Knob"
+	})
+	if !listed {
+		t.Fatalf("plugin global not listed")
+	}
+
+	// host writes, plugin reads
+	if _, err := rt.SetGlobal("plug.so:"+plugPkg+".Knob", 42); err != nil {
+		t.Fatalf("set plugin global err %s", err)
+	}
+	if got := call(t, p, "GetKnob"); got != 42 {
+		t.Fatalf("plugin reads Knob %d, want 42", got)
+	}
+	if _, err := rt.SetGlobalPath("plug.so:"+plugPkg+".conf.level", 9); err != nil {
+		t.Fatalf("set plugin global field err %s", err)
+	}
+	if got := call(t, p, "Level"); got != 9 {
+		t.Fatalf("plugin reads conf.level %d, want 9", got)
+	}
+
+	// plugin writes, host reads
+	setLevel, err := p.Lookup("SetLevel")
+	if err != nil {
+		t.Fatalf("lookup SetLevel err %s", err)
+	}
+	setLevel.(func(int))(7)
+	conf, err := rt.FindGlobal(plugPkg + ".conf")
+	if err != nil {
+		t.Fatalf("find plugin global err %s", err)
+	}
+	level, err := gort.FieldByPath(conf, "level")
+	if err != nil || level.Int() != 7 {
+		t.Fatalf("host reads conf.level %v, want 7: %v", level, err)
+	}
+
+	// a package shared by the host and the plugin resolves to the copy of the host
+	if _, err := rt.SetGlobal("github.com/lsg2020/gort/examples/plugin/shared.Counter", 11); err != nil {
+		t.Fatalf("set shared global err %s", err)
+	}
+	if got := call(t, p, "SharedCounter"); got != 11 || shared.Counter != 11 {
+		t.Fatalf("shared.Counter is %d in the plugin and %d in the host, want 11", got, shared.Counter)
+	}
+	// the copies of the plugin of the shared packages and the runtime are never used
+	for _, name := range []string{"github.com/lsg2020/gort/examples/plugin/shared.Counter", "runtime.mheap_"} {
+		if _, err := rt.FindGlobal("plug.so:" + name); !errors.Is(err, gort.ErrNotFound) {
+			t.Errorf("plugin copy of %s is listed: %v", name, err)
+		}
+	}
+	rt.ForeachGlobal(func(name string, v reflect.Value) {
+		if strings.HasPrefix(name, "plug.so:") && !strings.HasPrefix(name, "plug.so:"+plugPkg+".") {
+			t.Errorf("plugin copy of %s is listed", name)
+		}
+	})
+}
+
+// findPlugin returns the load address of the loaded library at path.
+func findPlugin(rt *gort.DwarfRT, path string) (uint64, error) {
+	libs, addrs, err := rt.SearchPlugins()
+	if err != nil {
+		return 0, err
+	}
+	for i, lib := range libs {
+		if lib == path {
+			return addrs[i], nil
+		}
+	}
+	return 0, gort.ErrNotFound
+}
+
+func call(t *testing.T, p *plugin.Plugin, name string) int {
+	sym, err := p.Lookup(name)
+	if err != nil {
+		t.Fatalf("lookup %s err %s", name, err)
+	}
+	return sym.(func() int)()
+}
@@ -0,0 +1,5 @@
+// Package shared is imported by both the plugin test and its plugin.
+package shared
+
+// Counter is linked once, the plugin uses the copy of the host.
+var Counter = 1
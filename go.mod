@@ -2,13 +2,15 @@ module github.com/lsg2020/gort
 
 go 1.18
 
-require github.com/go-delve/delve v1.8.3
+require (
+	github.com/go-delve/delve v1.8.3
+	golang.org/x/arch v0.0.0-20190927153633-4e8777c89be4
+)
 
 require (
 	github.com/cilium/ebpf v0.7.0 // indirect
 	github.com/hashicorp/golang-lru v0.5.4 // indirect
 	github.com/konsorten/go-windows-terminal-sequences v1.0.3 // indirect
 	github.com/sirupsen/logrus v1.6.0 // indirect
-	golang.org/x/arch v0.0.0-20190927153633-4e8777c89be4 // indirect
 	golang.org/x/sys v0.0.0-20211117180635-dee7805ff2e1 // indirect
 )
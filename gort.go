@@ -1,10 +1,12 @@
 package gort
 
 import (
+	"debug/dwarf"
 	"errors"
 	"os"
 	"reflect"
 	"runtime"
+	"sync"
 
 	"github.com/go-delve/delve/pkg/proc"
 )
@@ -14,18 +16,61 @@ var (
 	ErrNotFound         = errors.New("not found")
 	ErrNotSupport       = errors.New("not support")
 	ErrTooManyLibraries = errors.New("number of loaded libraries exceeds maximum")
+	ErrBuildIDMismatch  = errors.New("build id mismatch")
+	ErrAmbiguous        = errors.New("ambiguous")
+	ErrTimeout          = errors.New("timeout")
+	ErrInlined          = errors.New("inlined into its callers")
 )
 
 func NewDwarfRT(path string) (*DwarfRT, error) {
 	return (&DwarfRT{}).init(path)
 }
 
+// DwarfRT is safe for concurrent use by multiple goroutines.
 type DwarfRT struct {
-	bi *proc.BinaryInfo
+	// mu guards bi and snap. Lookups hold it for reading, AddImage holds it
+	// for writing while delve loads the new image and a new snapshot is published.
+	mu   sync.RWMutex
+	bi   *proc.BinaryInfo
+	snap *snapshot
 
-	mds             []moduleData
-	globals         map[string]reflect.Value
+	// dwarfMu serializes type decoding, delve and debug/dwarf both keep
+	// unsynchronized caches of the types they have read.
+	dwarfMu sync.Mutex
+
+	// synthesized maps types built from DWARF to their DWARF name.
+	synthesized sync.Map
+}
+
+// snapshot is the state derived from bi for the currently loaded images.
+// It is replaced as a whole by refreshModule, lazy indexes are built once.
+type snapshot struct {
+	mds []moduleData
+
+	globalsOnce  sync.Once
+	globals      map[string]reflect.Value // globals of other images are qualified, e.g. "libfoo.so:pkg.V"
+	globalImages map[string][]string      // unqualified name of the globals of other images to their qualified names
+	globalVars   map[string]*globalVar    // every variable found in DWARF by the key of globals, the skipped ones included
+	globalsErr   error                    // the variables of the executable could not be read
+
+	imageTypesMu    sync.Mutex
 	imageCacheTypes map[*proc.Image]map[string]uint64
+	imageTypeNames  map[*proc.Image]map[string]dwarf.Offset
+
+	genericsOnce sync.Once
+	generics     *genericIndex
+
+	dictsOnce sync.Once
+	dicts     map[string]dictSymbol
+
+	cuFilesMu sync.Mutex
+	cuFiles   map[*dwarf.Entry][]*dwarf.LineFile
+
+	constsOnce sync.Once
+	consts     map[string][]namedConst
+
+	packagesOnce sync.Once
+	packages     map[string]bool // package paths of the functions, globals and types
 }
 
 func (d *DwarfRT) init(path string) (*DwarfRT, error) {
@@ -50,6 +95,8 @@ func (d *DwarfRT) init(path string) (*DwarfRT, error) {
 }
 
 func (d *DwarfRT) AddImage(path string, addr uint64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	if err := d.check(); err != nil {
 		return err
 	}
@@ -65,8 +112,7 @@ func (d *DwarfRT) refreshModule() error {
 	if err != nil {
 		return err
 	}
-	d.mds = mds
-	d.globals = nil
+	d.snap = &snapshot{mds: mds}
 	return nil
 }
 
@@ -77,6 +123,18 @@ func (d *DwarfRT) check() error {
 	return nil
 }
 
+// rlock acquires d.mu for reading and checks d is initialized,
+// on success the caller must release it with d.mu.RUnlock.
+func (d *DwarfRT) rlock() error {
+	d.mu.RLock()
+	if err := d.check(); err != nil {
+		d.mu.RUnlock()
+		return err
+	}
+	return nil
+}
+
+// BI returns the underlying delve BinaryInfo, access to it is not synchronized with AddImage.
 func (d *DwarfRT) BI() *proc.BinaryInfo {
 	return d.bi
 }
@@ -0,0 +1,68 @@
+package gort
+
+import (
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"time"
+)
+
+// CallError is returned when a function can not be called with the given
+// arguments or panics while running.
+type CallError struct {
+	Func  string
+	Arg   int         // index of the offending argument, -1 when no single argument is at fault
+	Panic interface{} // value recovered from the function, nil when it did not panic
+	Stack []byte      // stack of the function when it panicked
+	Err   error
+}
+
+func (e *CallError) Error() string {
+	switch {
+	case e.Panic != nil:
+		return fmt.Sprintf("call %s panic: %v", e.Func, e.Panic)
+	case e.Arg >= 0:
+		return fmt.Sprintf("call %s arg %d: %s", e.Func, e.Arg, e.Err)
+	}
+	return fmt.Sprintf("call %s: %s", e.Func, e.Err)
+}
+
+func (e *CallError) Unwrap() error {
+	return e.Err
+}
+
+// callRecover calls fn, returning a panic in it as a *CallError.
+func callRecover(name string, fn reflect.Value, args []reflect.Value) (out []reflect.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			callErr := &CallError{Func: name, Arg: -1, Panic: r, Stack: debug.Stack()}
+			if rerr, ok := r.(error); ok {
+				callErr.Err = rerr
+			}
+			out, err = nil, callErr
+		}
+	}()
+	return fn.Call(args), nil
+}
+
+// callTimeout runs callRecover on its own goroutine and waits at most timeout for it.
+func callTimeout(name string, fn reflect.Value, args []reflect.Value, timeout time.Duration) ([]reflect.Value, error) {
+	type result struct {
+		out []reflect.Value
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := callRecover(name, fn, args)
+		done <- result{out, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.out, r.err
+	case <-timer.C:
+		return nil, &CallError{Func: name, Arg: -1, Err: ErrTimeout}
+	}
+}
@@ -0,0 +1,73 @@
+package gort_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lsg2020/gort"
+)
+
+var errDivide = errors.New("divide by zero")
+
+//go:noinline
+func callDivide(a, b int) int {
+	if b == 0 {
+		panic(errDivide)
+	}
+	return a / b
+}
+
+//go:noinline
+func callSleep(d time.Duration) int {
+	time.Sleep(d)
+	return 1
+}
+
+func TestCallFuncErrors(t *testing.T) {
+	rt := newRT(t)
+	if callDivide(4, 2) != 2 || callSleep(0) != 1 {
+		t.Fatalf("fixtures changed")
+	}
+
+	rets, err := rt.CallFunc(testPkg+".callDivide", false, []reflect.Value{reflect.ValueOf(9), reflect.ValueOf(3)})
+	if err != nil || rets[0].Int() != 3 {
+		t.Fatalf("call %v err %v", rets, err)
+	}
+
+	var callErr *gort.CallError
+	_, err = rt.CallFunc(testPkg+".callDivide", false, []reflect.Value{reflect.ValueOf(1), reflect.ValueOf(0)})
+	if !errors.As(err, &callErr) || callErr.Panic == nil || len(callErr.Stack) == 0 || !errors.Is(err, errDivide) {
+		t.Errorf("panicking call err %#v", err)
+	}
+
+	_, err = rt.CallFunc(testPkg+".callDivide", false, []reflect.Value{reflect.ValueOf(1), reflect.ValueOf("0")})
+	if !errors.As(err, &callErr) || callErr.Arg != 1 || callErr.Panic != nil {
+		t.Errorf("mistyped argument err %#v", err)
+	}
+	_, err = rt.CallFunc(testPkg+".callDivide", false, []reflect.Value{reflect.ValueOf(1)})
+	if !errors.As(err, &callErr) || callErr.Arg != -1 {
+		t.Errorf("missing argument err %#v", err)
+	}
+	_, err = rt.CallFunc(testPkg+".callDivide", true, []reflect.Value{reflect.ValueOf(1), reflect.ValueOf(2)})
+	if !errors.As(err, &callErr) {
+		t.Errorf("variadic call of a non variadic function err %#v", err)
+	}
+	if _, err := rt.CallFunc(testPkg+".missing", false, nil); !errors.Is(err, gort.ErrNotFound) {
+		t.Errorf("call of a missing function err %v, want ErrNotFound", err)
+	}
+}
+
+func TestCallFuncTimeout(t *testing.T) {
+	rt := newRT(t)
+	rets, err := rt.CallFuncTimeout(testPkg+".callSleep", false, []reflect.Value{reflect.ValueOf(time.Duration(0))}, time.Second)
+	if err != nil || rets[0].Int() != 1 {
+		t.Fatalf("call %v err %v", rets, err)
+	}
+	_, err = rt.CallFuncTimeout(testPkg+".callSleep", false, []reflect.Value{reflect.ValueOf(time.Second)}, 10*time.Millisecond)
+	var callErr *gort.CallError
+	if !errors.Is(err, gort.ErrTimeout) || !errors.As(err, &callErr) {
+		t.Errorf("slow call err %v, want ErrTimeout", err)
+	}
+}
@@ -0,0 +1,255 @@
+package gort
+
+import (
+	"debug/elf"
+	"fmt"
+	"reflect"
+	"strings"
+	"unsafe"
+
+	"github.com/go-delve/delve/pkg/proc"
+)
+
+// dictSymbol is the dictionary of a generic function instantiation.
+type dictSymbol struct {
+	addr uint64
+	size uint64
+}
+
+// genericCall is a shape function prepared to be called for one instantiation.
+type genericCall struct {
+	fn      *proc.Function
+	dict    dictSymbol
+	params  []dwarfParam
+	in, out []reflect.Type // instantiated parameter types, including the dictionary
+}
+
+// CallGeneric calls the instantiation of the generic function name with the
+// type arguments typeArgs, e.g. CallGeneric(rt, "main.Map", []string{"int", "string"}, args).
+// Generic functions are compiled to shape functions shared by instantiations
+// with the same memory layout, which take the dictionary of the instantiation
+// as a hidden first argument. The instantiation must have been compiled into
+// the binary, variadic parameters take a slice. Generic methods are not supported.
+func CallGeneric(d *DwarfRT, name string, typeArgs []string, args []reflect.Value) ([]reflect.Value, error) {
+	base := stripTypeArgs(name)
+	normalized := make([]string, len(typeArgs))
+	for i, arg := range typeArgs {
+		normalized[i] = normalizeTypeName(arg)
+	}
+	inst := base + "[" + strings.Join(normalized, ",") + "]"
+
+	if err := d.rlock(); err != nil {
+		return nil, err
+	}
+	call, err := d.prepareGenericCall(base, inst, normalized)
+	d.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	callArgs := make([]reflect.Value, 0, len(call.in))
+	callArgs = append(callArgs, dictArg(call.in[0], call.dict.addr))
+	inTyps := call.in[1:]
+	var inNames []string
+	for _, param := range call.params[1:] {
+		if !param.ret {
+			inNames = append(inNames, param.name)
+		}
+	}
+	if err := checkCallArgs(inst, inTyps, inNames, false, args); err != nil {
+		return nil, err
+	}
+	callArgs = append(callArgs, args...)
+
+	fn := CreateFuncForCodePtr(reflect.FuncOf(call.in, call.out, false), call.fn.Entry)
+	return callRecover(inst, fn, callArgs)
+}
+
+// prepareGenericCall finds the dictionary of inst and the shape function of
+// base it belongs to. d.mu must be held.
+func (d *DwarfRT) prepareGenericCall(base, inst string, typeArgs []string) (*genericCall, error) {
+	pkg, local := splitPackage(base)
+	if pkg == "" || strings.ContainsAny(local, ".(") {
+		return nil, fmt.Errorf("generic function %s %w", base, ErrNotSupport)
+	}
+	_, instLocal := splitPackage(inst)
+	dict, ok := d.loadDicts()[pkg+"..dict."+instLocal]
+	if !ok {
+		return nil, fmt.Errorf("generic instantiation %s was not compiled into the binary: %w", inst, ErrNotFound)
+	}
+
+	concrete := make([]reflect.Type, len(typeArgs))
+	for i, arg := range typeArgs {
+		typ, err := d.lookupType(arg)
+		if err != nil {
+			return nil, fmt.Errorf("generic instantiation %s type argument %s: %w", inst, arg, err)
+		}
+		concrete[i] = typ
+	}
+
+	var lastErr error
+	for _, shape := range d.loadGenerics().insts[base] {
+		if !shape.Func || !shape.Shape || len(shape.TypeArgs) != len(typeArgs) || stripTypeArgs(shape.Name) != base {
+			continue
+		}
+		if err := d.matchShapeArgs(shape, concrete); err != nil {
+			lastErr = err
+			continue
+		}
+		f, err := d.findFunc(shape.Name)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		call, err := d.genericCallFor(f, dict)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return call, nil
+	}
+	if lastErr != nil {
+		return nil, fmt.Errorf("shape function of generic instantiation %s %w: %s", inst, ErrNotFound, lastErr)
+	}
+	return nil, fmt.Errorf("shape function of generic instantiation %s %w", inst, ErrNotFound)
+}
+
+// matchShapeArgs checks every type argument of the shape function shape has
+// the shape of the concrete type argument, including type parameters no
+// parameter of the function uses. d.mu must be held.
+func (d *DwarfRT) matchShapeArgs(shape Instantiation, concrete []reflect.Type) error {
+	for i, arg := range shape.TypeArgs {
+		typ, err := d.lookupType(arg)
+		if err != nil {
+			return fmt.Errorf("shape function %s type argument %s: %w", shape.Name, arg, err)
+		}
+		if !sameShape(concrete[i], typ) {
+			return fmt.Errorf("shape function %s type argument %d is %s, instantiation has %s", shape.Name, i, arg, concrete[i])
+		}
+	}
+	return nil
+}
+
+// genericCallFor resolves the parameters of the shape function f for the
+// instantiation with dictionary dict, types of parameters holding type
+// parameters are read from the dictionary and must have the shape layout.
+func (d *DwarfRT) genericCallFor(f *proc.Function, dict dictSymbol) (*genericCall, error) {
+	params, err := d.funcParams(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(params) == 0 || params[0].name != ".dict" {
+		return nil, fmt.Errorf("shape function %s has no dictionary parameter", f.Name)
+	}
+
+	call := &genericCall{fn: f, dict: dict, params: params}
+	ptrSize := uint64(unsafe.Sizeof(uintptr(0)))
+	for _, param := range params {
+		typ, err := d.lookupType(param.typeName)
+		if err != nil {
+			return nil, err
+		}
+		if param.dictIndex >= 0 {
+			if uint64(param.dictIndex+1)*ptrSize > dict.size {
+				return nil, fmt.Errorf("shape function %s dictionary index %d out of range", f.Name, param.dictIndex)
+			}
+			typeAddr := *(*uintptr)(addrPointer(dict.addr + uint64(param.dictIndex)*ptrSize))
+			if typeAddr == 0 {
+				return nil, fmt.Errorf("shape function %s dictionary entry %d is empty", f.Name, param.dictIndex)
+			}
+			concrete := runtimeTypeAt(uint64(typeAddr))
+			if !sameShape(concrete, typ) {
+				return nil, fmt.Errorf("shape function %s parameter %s is %s, instantiation has %s", f.Name, param.name, typ, concrete)
+			}
+			typ = concrete
+		}
+		if param.ret {
+			call.out = append(call.out, typ)
+		} else {
+			call.in = append(call.in, typ)
+		}
+	}
+	return call, nil
+}
+
+// sameShape reports whether a and b have the same layout and their scalars
+// behave the same, so code compiled for one works on the other. Synthesized
+// types stand in function, map and channel values with unsafe.Pointer.
+func sameShape(a, b reflect.Type) bool {
+	if a.Size() != b.Size() {
+		return false
+	}
+	ka, kb := shapeKind(a.Kind()), shapeKind(b.Kind())
+	if ka != kb {
+		return false
+	}
+	switch ka {
+	case reflect.Slice, reflect.Array:
+		if ka == reflect.Array && a.Len() != b.Len() {
+			return false
+		}
+		return sameShape(a.Elem(), b.Elem())
+	case reflect.Interface:
+		return (a.NumMethod() == 0) == (b.NumMethod() == 0)
+	case reflect.Struct:
+		if a.NumField() != b.NumField() {
+			return false
+		}
+		for i := 0; i < a.NumField(); i++ {
+			fa, fb := a.Field(i), b.Field(i)
+			if fa.Offset != fb.Offset || !sameShape(fa.Type, fb.Type) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// shapeKind maps kinds to a representative kind of the same register class and semantics.
+func shapeKind(kind reflect.Kind) reflect.Kind {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.Int
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return reflect.Uint
+	case reflect.Ptr, reflect.UnsafePointer, reflect.Func, reflect.Map, reflect.Chan:
+		return reflect.Ptr
+	}
+	return kind
+}
+
+// loadDicts maps the dictionary symbols of generic instantiations, such as
+// "main..dict.Map[int,string]", to their address.
+func (d *DwarfRT) loadDicts() map[string]dictSymbol {
+	snap := d.snap
+	snap.dictsOnce.Do(func() {
+		snap.dicts = make(map[string]dictSymbol)
+		for _, img := range d.bi.Images {
+			f, err := elf.Open(img.Path)
+			if err != nil {
+				continue
+			}
+			symbols, _ := f.Symbols()
+			f.Close()
+			for _, sym := range symbols {
+				if !strings.Contains(sym.Name, "..dict.") {
+					continue
+				}
+				if _, exists := snap.dicts[sym.Name]; !exists {
+					snap.dicts[sym.Name] = dictSymbol{addr: sym.Value + img.StaticBase, size: sym.Size}
+				}
+			}
+		}
+	})
+	return snap.dicts
+}
+
+// dictArg returns the dictionary at addr as a value of the .dict parameter type typ.
+func dictArg(typ reflect.Type, addr uint64) reflect.Value {
+	if typ.Kind() == reflect.Ptr {
+		return reflect.NewAt(typ.Elem(), addrPointer(addr))
+	}
+	v := reflect.New(typ).Elem()
+	v.SetUint(addr)
+	return v
+}
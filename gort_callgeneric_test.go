@@ -0,0 +1,96 @@
+package gort_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lsg2020/gort"
+)
+
+type number interface {
+	~int | ~int64 | ~float64
+}
+
+type describeT struct {
+	x int
+}
+
+//go:noinline
+func genericMap[A, B any](in []A, f func(A) B) []B {
+	out := make([]B, 0, len(in))
+	for _, v := range in {
+		out = append(out, f(v))
+	}
+	return out
+}
+
+//go:noinline
+func genericSum[N number](ns ...N) N {
+	var s N
+	for _, n := range ns {
+		s += n
+	}
+	return s
+}
+
+//go:noinline
+func genericDescribe[V any](v V) string {
+	return fmt.Sprintf("%T:%v", v, v)
+}
+
+func TestCallGeneric(t *testing.T) {
+	rt := newRT(t)
+	genericMap([]int{1}, strconv.Itoa)
+	genericSum(1, 2)
+	genericSum(time.Second)
+	genericDescribe(&describeT{})
+	genericDescribe(describeT{})
+
+	rets, err := gort.CallGeneric(rt, testPkg+".genericMap", []string{"int", "string"}, []reflect.Value{
+		reflect.ValueOf([]int{7, 8}),
+		reflect.ValueOf(strconv.Itoa),
+	})
+	if err != nil {
+		t.Fatalf("call genericMap err %s", err)
+	}
+	if out, ok := rets[0].Interface().([]string); !ok || !reflect.DeepEqual(out, []string{"7", "8"}) {
+		t.Errorf("genericMap returned %#v", rets[0].Interface())
+	}
+
+	// instantiations sharing a shape are told apart by their dictionary
+	rets, err = gort.CallGeneric(rt, testPkg+".genericSum", []string{"time.Duration"}, []reflect.Value{
+		reflect.ValueOf([]time.Duration{time.Second, time.Minute}),
+	})
+	if err != nil {
+		t.Fatalf("call genericSum err %s", err)
+	}
+	if d, ok := rets[0].Interface().(time.Duration); !ok || d != time.Second+time.Minute {
+		t.Errorf("genericSum returned %#v", rets[0].Interface())
+	}
+	for typeArg, arg := range map[string]interface{}{
+		"*" + testPkg + ".describeT": &describeT{1},
+		testPkg + ".describeT":       describeT{2},
+	} {
+		rets, err := gort.CallGeneric(rt, testPkg+".genericDescribe", []string{typeArg}, []reflect.Value{reflect.ValueOf(arg)})
+		if err != nil {
+			t.Fatalf("call genericDescribe[%s] err %s", typeArg, err)
+		}
+		if want := genericDescribe(arg); rets[0].String() != want {
+			t.Errorf("genericDescribe[%s] returned %q, want %q", typeArg, rets[0].String(), want)
+		}
+	}
+
+	_, err = gort.CallGeneric(rt, testPkg+".genericDescribe", []string{"complex128"}, []reflect.Value{reflect.ValueOf(1i)})
+	if !errors.Is(err, gort.ErrNotFound) {
+		t.Errorf("call of an instantiation not in the binary err %v, want ErrNotFound", err)
+	}
+	var callErr *gort.CallError
+	_, err = gort.CallGeneric(rt, testPkg+".genericDescribe", []string{testPkg + ".describeT"}, []reflect.Value{reflect.ValueOf(1)})
+	if !errors.As(err, &callErr) || callErr.Arg != 0 {
+		t.Errorf("mistyped argument err %v", err)
+	}
+}
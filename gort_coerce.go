@@ -0,0 +1,411 @@
+package gort
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CoerceError is returned when a value can not be coerced, Path locates the
+// offending element in the value, e.g. `.items[2].size` or `["key"]`.
+type CoerceError struct {
+	Path string
+	Type reflect.Type // type the element was coerced to
+	Err  error
+}
+
+func (e *CoerceError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("coerce to %s: %s", e.Type, e.Err)
+	}
+	return fmt.Sprintf("coerce %s to %s: %s", e.Path, e.Type, e.Err)
+}
+
+func (e *CoerceError) Unwrap() error {
+	return e.Err
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Coerce converts v, typically decoded from JSON or parsed from strings, to
+// typ. Numbers convert between kinds when they are represented exactly and
+// parse from strings, as do bools and durations. Slices and arrays convert
+// element wise, maps key and value wise, and maps with string keys fill
+// structs by field name, json tag or case insensitive name, unexported fields
+// included. Pointers are allocated or followed as needed, nil becomes the zero value.
+func Coerce(v interface{}, typ reflect.Type) (reflect.Value, error) {
+	if rv, ok := v.(reflect.Value); ok {
+		return coerce(rv, typ, "")
+	}
+	return coerce(reflect.ValueOf(v), typ, "")
+}
+
+// CallFuncLoose is CallFunc with each argument coerced to its parameter type.
+func (d *DwarfRT) CallFuncLoose(name string, variadic bool, args []interface{}) ([]reflect.Value, error) {
+	sig, err := d.Signature(name)
+	if err != nil {
+		return nil, err
+	}
+	params := sig.In
+	if variadic && (len(params) == 0 || params[len(params)-1].Type.Kind() != reflect.Slice) {
+		return nil, notVariadicError(name)
+	}
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var typ reflect.Type
+		switch {
+		case variadic && i >= len(params)-1:
+			typ = params[len(params)-1].Type.Elem()
+		case i < len(params):
+			typ = params[i].Type
+		default:
+			// too many arguments, CallFunc reports it
+			in[i] = reflect.ValueOf(arg)
+			continue
+		}
+		if in[i], err = Coerce(arg, typ); err != nil {
+			return nil, &CallError{Func: name, Arg: i, Err: err}
+		}
+	}
+	return d.CallFunc(name, variadic, in)
+}
+
+func coerce(v reflect.Value, typ reflect.Type, path string) (reflect.Value, error) {
+	for v.IsValid() && v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return reflect.Zero(typ), nil
+	}
+	if v.Type().AssignableTo(typ) {
+		return copyValue(v, typ), nil
+	}
+	fail := func(format string, args ...interface{}) (reflect.Value, error) {
+		return reflect.Value{}, &CoerceError{Path: path, Type: typ, Err: fmt.Errorf(format, args...)}
+	}
+
+	if typ == durationType && v.Kind() == reflect.String {
+		d, err := time.ParseDuration(v.String())
+		if err != nil {
+			return fail("%s", err)
+		}
+		return reflect.ValueOf(d), nil
+	}
+
+	switch kind := typ.Kind(); {
+	case kind == reflect.Ptr:
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Zero(typ), nil
+			}
+			v = v.Elem()
+		}
+		elem, err := coerce(v, typ.Elem(), path)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		p := reflect.New(typ.Elem())
+		p.Elem().Set(elem)
+		return p, nil
+	case v.Kind() == reflect.Ptr:
+		if v.IsNil() {
+			return reflect.Zero(typ), nil
+		}
+		return coerce(v.Elem(), typ, path)
+	case kind == reflect.Interface:
+		if v.Type().Implements(typ) {
+			return v.Convert(typ), nil
+		}
+		return fail("%s does not implement it", v.Type())
+	case isInt(kind), isUint(kind), isFloat(kind):
+		return coerceNumber(v, typ, fail)
+	case kind == reflect.Bool:
+		switch v.Kind() {
+		case reflect.Bool:
+			return v.Convert(typ), nil
+		case reflect.String:
+			b, err := strconv.ParseBool(v.String())
+			if err != nil {
+				return fail("invalid bool %q", v.String())
+			}
+			return reflect.ValueOf(b).Convert(typ), nil
+		}
+	case kind == reflect.String:
+		switch {
+		case v.Kind() == reflect.String:
+			return v.Convert(typ), nil
+		case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+			return reflect.ValueOf(string(v.Bytes())).Convert(typ), nil
+		}
+	case kind == reflect.Slice:
+		switch v.Kind() {
+		case reflect.String:
+			if typ.Elem().Kind() == reflect.Uint8 {
+				return reflect.ValueOf([]byte(v.String())).Convert(typ), nil
+			}
+		case reflect.Slice, reflect.Array:
+			if v.Kind() == reflect.Slice && v.IsNil() {
+				return reflect.Zero(typ), nil
+			}
+			s := reflect.MakeSlice(typ, v.Len(), v.Len())
+			if err := coerceElems(v, s, path); err != nil {
+				return reflect.Value{}, err
+			}
+			return s, nil
+		}
+	case kind == reflect.Array:
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			if v.Len() != typ.Len() {
+				return fail("%d elements, expected %d", v.Len(), typ.Len())
+			}
+			a := reflect.New(typ).Elem()
+			if err := coerceElems(v, a, path); err != nil {
+				return reflect.Value{}, err
+			}
+			return a, nil
+		}
+	case kind == reflect.Map:
+		if v.Kind() == reflect.Map {
+			return coerceMap(v, typ, path)
+		}
+	case kind == reflect.Struct:
+		switch v.Kind() {
+		case reflect.Map:
+			return coerceMapToStruct(v, typ, path, fail)
+		case reflect.Struct:
+			return coerceStruct(v, typ, path)
+		}
+	}
+	return fail("can not convert %s", v.Type())
+}
+
+// copyValue returns v as a value of typ, v may have been reached through
+// unexported fields of an addressable struct.
+func copyValue(v reflect.Value, typ reflect.Type) reflect.Value {
+	if !v.CanInterface() && v.CanAddr() {
+		v = writable(v)
+	}
+	c := reflect.New(typ).Elem()
+	c.Set(v)
+	return c
+}
+
+// coerceNumber converts the number or numeric string v to the number type typ,
+// failing when the value is not represented exactly.
+func coerceNumber(v reflect.Value, typ reflect.Type, fail func(string, ...interface{}) (reflect.Value, error)) (reflect.Value, error) {
+	kind := typ.Kind()
+	bits := typ.Bits()
+	out := reflect.New(typ).Elem()
+	if v.Kind() == reflect.String {
+		s := strings.TrimSpace(v.String())
+		var err error
+		switch {
+		case isInt(kind):
+			var i int64
+			if i, err = strconv.ParseInt(s, 0, bits); err == nil {
+				out.SetInt(i)
+				return out, nil
+			}
+		case isUint(kind):
+			var u uint64
+			if u, err = strconv.ParseUint(s, 0, bits); err == nil {
+				out.SetUint(u)
+				return out, nil
+			}
+		default:
+			var f float64
+			if f, err = strconv.ParseFloat(s, bits); err == nil {
+				out.SetFloat(f)
+				return out, nil
+			}
+		}
+		if ne, ok := err.(*strconv.NumError); ok {
+			err = ne.Err
+		}
+		return fail("%q: %s", s, err)
+	}
+
+	switch {
+	case isInt(v.Kind()):
+		i := v.Int()
+		switch {
+		case isInt(kind):
+			if out.OverflowInt(i) {
+				return fail("%d overflows", i)
+			}
+			out.SetInt(i)
+		case isUint(kind):
+			if i < 0 || out.OverflowUint(uint64(i)) {
+				return fail("%d overflows", i)
+			}
+			out.SetUint(uint64(i))
+		default:
+			out.SetFloat(float64(i))
+		}
+	case isUint(v.Kind()):
+		u := v.Uint()
+		switch {
+		case isInt(kind):
+			if u > math.MaxInt64 || out.OverflowInt(int64(u)) {
+				return fail("%d overflows", u)
+			}
+			out.SetInt(int64(u))
+		case isUint(kind):
+			if out.OverflowUint(u) {
+				return fail("%d overflows", u)
+			}
+			out.SetUint(u)
+		default:
+			out.SetFloat(float64(u))
+		}
+	case isFloat(v.Kind()):
+		f := v.Float()
+		switch {
+		case isFloat(kind):
+			if out.OverflowFloat(f) {
+				return fail("%v overflows", f)
+			}
+			out.SetFloat(f)
+		case f != math.Trunc(f) || math.IsInf(f, 0) || math.IsNaN(f):
+			return fail("%v is not an integer", f)
+		case isInt(kind):
+			if f < math.MinInt64 || f >= math.MaxInt64 || out.OverflowInt(int64(f)) {
+				return fail("%v overflows", f)
+			}
+			out.SetInt(int64(f))
+		default:
+			if f < 0 || f >= math.MaxUint64 || out.OverflowUint(uint64(f)) {
+				return fail("%v overflows", f)
+			}
+			out.SetUint(uint64(f))
+		}
+	default:
+		return fail("can not convert %s", v.Type())
+	}
+	return out, nil
+}
+
+// coerceElems coerces the elements of the slice or array v into dst.
+func coerceElems(v, dst reflect.Value, path string) error {
+	for i := 0; i < v.Len(); i++ {
+		elem, err := coerce(v.Index(i), dst.Type().Elem(), path+"["+strconv.Itoa(i)+"]")
+		if err != nil {
+			return err
+		}
+		dst.Index(i).Set(elem)
+	}
+	return nil
+}
+
+func coerceMap(v reflect.Value, typ reflect.Type, path string) (reflect.Value, error) {
+	if v.IsNil() {
+		return reflect.Zero(typ), nil
+	}
+	m := reflect.MakeMapWithSize(typ, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		elemPath := path + "[" + keyPath(iter.Key()) + "]"
+		key, err := coerce(iter.Key(), typ.Key(), elemPath)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		elem, err := coerce(iter.Value(), typ.Elem(), elemPath)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		m.SetMapIndex(key, elem)
+	}
+	return m, nil
+}
+
+// keyPath formats a map key for a path.
+func keyPath(key reflect.Value) string {
+	for key.Kind() == reflect.Interface && !key.IsNil() {
+		key = key.Elem()
+	}
+	if key.Kind() == reflect.String {
+		return strconv.Quote(key.String())
+	}
+	if key.CanInterface() {
+		return fmt.Sprint(key.Interface())
+	}
+	return key.Type().String()
+}
+
+// coerceMapToStruct fills a struct of type typ from the map v with string keys.
+func coerceMapToStruct(v reflect.Value, typ reflect.Type, path string, fail func(string, ...interface{}) (reflect.Value, error)) (reflect.Value, error) {
+	if v.Type().Key().Kind() != reflect.String && v.Type().Key().Kind() != reflect.Interface {
+		return fail("can not convert %s", v.Type())
+	}
+	s := reflect.New(typ).Elem()
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keyPath(keys[i]) < keyPath(keys[j]) })
+	for _, key := range keys {
+		k := key
+		for k.Kind() == reflect.Interface && !k.IsNil() {
+			k = k.Elem()
+		}
+		if k.Kind() != reflect.String {
+			return fail("field name %s is not a string", keyPath(key))
+		}
+		index := structFieldIndex(typ, k.String())
+		if index < 0 {
+			return reflect.Value{}, &CoerceError{Path: path + "." + k.String(), Type: typ, Err: fmt.Errorf("no such field")}
+		}
+		field := typ.Field(index)
+		elem, err := coerce(v.MapIndex(key), field.Type, path+"."+k.String())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		writable(s.Field(index)).Set(elem)
+	}
+	return s, nil
+}
+
+// coerceStruct converts between struct types with fields of the same names,
+// such as a synthesized type and the type it stands in for.
+func coerceStruct(v reflect.Value, typ reflect.Type, path string) (reflect.Value, error) {
+	if !v.CanAddr() {
+		// fields of an addressable struct can be read even when unexported
+		addressable := reflect.New(v.Type()).Elem()
+		addressable.Set(v)
+		v = addressable
+	}
+	s := reflect.New(typ).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		index := structFieldIndex(typ, name)
+		if index < 0 {
+			return reflect.Value{}, &CoerceError{Path: path + "." + name, Type: typ, Err: fmt.Errorf("no such field")}
+		}
+		elem, err := coerce(writable(v.Field(i)), typ.Field(index).Type, path+"."+name)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		writable(s.Field(index)).Set(elem)
+	}
+	return s, nil
+}
+
+// structFieldIndex returns the index of the field of typ named name, matching
+// the field name, its synthesized name, its json tag, then case insensitively.
+func structFieldIndex(typ reflect.Type, name string) int {
+	fold := -1
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Name == name || field.Name == synthesizedFieldName(name, i) {
+			return i
+		}
+		if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag != "" && tag != "-" && tag == name {
+			return i
+		}
+		if fold < 0 && strings.EqualFold(field.Name, name) {
+			fold = i
+		}
+	}
+	return fold
+}
@@ -0,0 +1,104 @@
+package gort_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lsg2020/gort"
+)
+
+type coerceItem struct {
+	Size int `json:"size"`
+}
+
+type coerceRequest struct {
+	ID      int64
+	Items   []coerceItem `json:"items"`
+	Timeout time.Duration
+	Tags    map[string]uint8
+	secret  *string
+}
+
+//go:noinline
+func coerceHandle(req *coerceRequest, port int) int {
+	total := int(req.ID) + port
+	for _, item := range req.Items {
+		total += item.Size
+	}
+	return total
+}
+
+func TestCoerce(t *testing.T) {
+	var input interface{}
+	if err := json.Unmarshal([]byte(`{"id": 12, "items": [{"size": 3}, {"size": "4"}], "timeout": "1.5s", "TAGS": {"a": 1}, "secret": "s"}`), &input); err != nil {
+		t.Fatal(err)
+	}
+	v, err := gort.Coerce(input, reflect.TypeOf(coerceRequest{}))
+	if err != nil {
+		t.Fatalf("coerce err %s", err)
+	}
+	req := v.Interface().(coerceRequest)
+	if req.ID != 12 || !reflect.DeepEqual(req.Items, []coerceItem{{3}, {4}}) || req.Timeout != 1500*time.Millisecond ||
+		req.Tags["a"] != 1 || req.secret == nil || *req.secret != "s" {
+		t.Errorf("coerced %+v", req)
+	}
+
+	for _, test := range []struct {
+		v    interface{}
+		typ  reflect.Type
+		want interface{}
+	}{
+		{3.0, reflect.TypeOf(int8(0)), int8(3)},
+		{"80", reflect.TypeOf(uint16(0)), uint16(80)},
+		{"true", reflect.TypeOf(false), true},
+		{nil, reflect.TypeOf((*int)(nil)), (*int)(nil)},
+		{[]interface{}{1.0, "2"}, reflect.TypeOf([2]int{}), [2]int{1, 2}},
+	} {
+		v, err := gort.Coerce(test.v, test.typ)
+		if err != nil {
+			t.Errorf("coerce %#v to %s err %s", test.v, test.typ, err)
+			continue
+		}
+		if !reflect.DeepEqual(v.Interface(), test.want) {
+			t.Errorf("coerce %#v to %s = %#v", test.v, test.typ, v.Interface())
+		}
+	}
+
+	var coerceErr *gort.CoerceError
+	_, err = gort.Coerce(map[string]interface{}{"items": []interface{}{map[string]interface{}{"size": "big"}}}, reflect.TypeOf(coerceRequest{}))
+	if !errors.As(err, &coerceErr) || coerceErr.Path != ".items[0].size" {
+		t.Errorf("coerce error %v", err)
+	}
+	for _, v := range []interface{}{300.0, 1.5, -1.0} {
+		if _, err := gort.Coerce(v, reflect.TypeOf(uint8(0))); err == nil {
+			t.Errorf("coerce %v to uint8 succeeded", v)
+		}
+	}
+}
+
+func TestCallFuncLoose(t *testing.T) {
+	rt := newRT(t)
+	if coerceHandle(&coerceRequest{}, 0) != 0 {
+		t.Fatalf("fixtures changed")
+	}
+	var args []interface{}
+	if err := json.Unmarshal([]byte(`[{"id": 12, "items": [{"size": 3}]}, "80"]`), &args); err != nil {
+		t.Fatal(err)
+	}
+	rets, err := rt.CallFuncLoose(testPkg+".coerceHandle", false, args)
+	if err != nil {
+		t.Fatalf("call err %s", err)
+	}
+	if n := rets[0].Int(); n != 95 {
+		t.Errorf("coerceHandle returned %d", n)
+	}
+
+	var callErr *gort.CallError
+	_, err = rt.CallFuncLoose(testPkg+".coerceHandle", false, []interface{}{nil, "eighty"})
+	if !errors.As(err, &callErr) || callErr.Arg != 1 {
+		t.Errorf("uncoercible argument err %v", err)
+	}
+}
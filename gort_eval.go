@@ -0,0 +1,913 @@
+package gort
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Eval evaluates a Go expression over the globals, functions and types of
+// the binary, e.g. `main.cache.m["key"].size` or `strings.ToUpper(main.name)`.
+// Qualified identifiers resolve through FindGlobal, FindType and FindFunc,
+// package paths may contain slashes. Supported are field selection, including
+// unexported fields, methods, indexing, slicing, dereference, address of,
+// conversions, calls, len and cap, and unary and binary operators on basic
+// values. A call returning a value and an error evaluates to the value or
+// fails with the error, a call without results evaluates to the invalid Value.
+func (d *DwarfRT) Eval(expr string) (reflect.Value, error) {
+	if err := d.rlock(); err != nil {
+		return reflect.Value{}, err
+	}
+	known := d.loadPackages()
+	d.mu.RUnlock()
+	src, pkgs := rewritePackagePaths(expr, known)
+	node, err := parser.ParseExpr(src)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("eval %s: %w", expr, err)
+	}
+	e := &evaluator{d: d, src: src, pkgs: pkgs}
+	v, err := e.eval(node)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("eval %s: %w", expr, err)
+	}
+	return v.v, nil
+}
+
+// evaluator holds the state of one Eval.
+type evaluator struct {
+	d    *DwarfRT
+	src  string
+	pkgs map[string]string // placeholder identifier to package path
+}
+
+// evalValue is an evaluated operand, untyped constants take the type of the
+// other operand or parameter they are used with.
+type evalValue struct {
+	v       reflect.Value
+	untyped bool
+	fn      string // name of a function found by FindFunc, it is rebuilt when called variadic
+}
+
+// packagePathRegexp matches package paths containing a slash followed by a selector.
+var packagePathRegexp = regexp.MustCompile(`[A-Za-z_][\w.\-]*(?:/[\w.\-]+)+\.`)
+
+// rewritePackagePaths replaces the known package paths with slashes outside
+// of literals by placeholder identifiers the Go parser accepts. Other matches
+// are left alone, such as the division main.total/main.count.
+func rewritePackagePaths(expr string, known map[string]bool) (string, map[string]string) {
+	pkgs := make(map[string]string)
+	ids := make(map[string]string)
+	var b strings.Builder
+	for i := 0; i < len(expr); {
+		switch c := expr[i]; c {
+		case '"', '`', '\'':
+			end := i + 1
+			for end < len(expr) && expr[end] != c {
+				if expr[end] == '\\' && c != '`' {
+					end++
+				}
+				end++
+			}
+			if end < len(expr) {
+				end++
+			}
+			b.WriteString(expr[i:end])
+			i = end
+			continue
+		}
+		if path := knownPackagePath(expr[i:], known); path != "" && (i == 0 || !isIdentByte(expr[i-1])) {
+			id, ok := ids[path]
+			if !ok {
+				id = "_gortpkg" + strconv.Itoa(len(ids))
+				ids[path] = id
+				pkgs[id] = path
+			}
+			b.WriteString(id + ".")
+			i += len(path) + 1
+			continue
+		}
+		b.WriteByte(expr[i])
+		i++
+	}
+	return b.String(), pkgs
+}
+
+// knownPackagePath returns the longest known package path with a slash s
+// starts with followed by a selector, or "".
+func knownPackagePath(s string, known map[string]bool) string {
+	loc := packagePathRegexp.FindStringIndex(s)
+	if loc == nil || loc[0] != 0 {
+		return ""
+	}
+	slash := strings.IndexByte(s, '/')
+	for end := loc[1] - 1; end > slash; end-- {
+		if s[end] == '.' && known[s[:end]] {
+			return s[:end]
+		}
+	}
+	return ""
+}
+
+// loadPackages returns the package paths of the functions, globals and types. d.mu must be held.
+func (d *DwarfRT) loadPackages() map[string]bool {
+	snap := d.snap
+	snap.packagesOnce.Do(func() {
+		snap.packages = make(map[string]bool)
+		add := func(name string) {
+			if pkg, _ := splitPackage(name); pkg != "" {
+				snap.packages[pkg] = true
+			}
+		}
+		for _, fn := range d.bi.Functions {
+			add(fn.Name)
+		}
+		for name := range d.loadGlobals() {
+			// globals of other images are qualified, e.g. "libfoo.so:pkg.V"
+			add(name[strings.IndexByte(name, ':')+1:])
+		}
+		types, _ := d.bi.Types()
+		for _, name := range types {
+			add(name)
+		}
+	})
+	return snap.packages
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '.' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func (e *evaluator) text(node ast.Node) string {
+	text := e.src[node.Pos()-1 : node.End()-1]
+	for id, path := range e.pkgs {
+		text = strings.ReplaceAll(text, id+".", path+".")
+	}
+	return text
+}
+
+func (e *evaluator) eval(node ast.Expr) (evalValue, error) {
+	switch node := node.(type) {
+	case *ast.ParenExpr:
+		return e.eval(node.X)
+	case *ast.BasicLit:
+		return evalLiteral(node)
+	case *ast.Ident:
+		return e.evalIdent(node)
+	case *ast.SelectorExpr:
+		return e.evalSelector(node)
+	case *ast.IndexExpr:
+		return e.evalIndex(node)
+	case *ast.SliceExpr:
+		return e.evalSlice(node)
+	case *ast.StarExpr:
+		x, err := e.eval(node.X)
+		if err != nil {
+			return evalValue{}, err
+		}
+		if x.v.Kind() != reflect.Ptr {
+			return evalValue{}, fmt.Errorf("%s is not a pointer", e.text(node.X))
+		}
+		if x.v.IsNil() {
+			return evalValue{}, fmt.Errorf("%s is nil", e.text(node.X))
+		}
+		return evalValue{v: writable(x.v.Elem())}, nil
+	case *ast.UnaryExpr:
+		return e.evalUnary(node)
+	case *ast.BinaryExpr:
+		return e.evalBinary(node)
+	case *ast.CallExpr:
+		return e.evalCall(node)
+	}
+	return evalValue{}, fmt.Errorf("unsupported expression %s", e.text(node))
+}
+
+func evalLiteral(lit *ast.BasicLit) (evalValue, error) {
+	c := constant.MakeFromLiteral(lit.Value, lit.Kind, 0)
+	var v interface{}
+	switch c.Kind() {
+	case constant.String:
+		v = constant.StringVal(c)
+	case constant.Int:
+		i, ok := constant.Int64Val(c)
+		if !ok {
+			return evalValue{}, fmt.Errorf("constant %s overflows int", lit.Value)
+		}
+		if lit.Kind == token.CHAR {
+			v = rune(i)
+		} else {
+			v = int(i)
+		}
+	case constant.Float:
+		v, _ = constant.Float64Val(c)
+	case constant.Complex:
+		re, _ := constant.Float64Val(constant.Real(c))
+		im, _ := constant.Float64Val(constant.Imag(c))
+		v = complex(re, im)
+	default:
+		return evalValue{}, fmt.Errorf("invalid literal %s", lit.Value)
+	}
+	return evalValue{v: reflect.ValueOf(v), untyped: true}, nil
+}
+
+func (e *evaluator) evalIdent(id *ast.Ident) (evalValue, error) {
+	switch id.Name {
+	case "true", "false":
+		return evalValue{v: reflect.ValueOf(id.Name == "true"), untyped: true}, nil
+	case "nil":
+		return evalValue{untyped: true}, nil
+	}
+	return evalValue{}, fmt.Errorf("identifier %s is not package qualified", id.Name)
+}
+
+// qualifiedIdent returns the package qualified name of a selector such as main.x.
+func (e *evaluator) qualifiedIdent(sel *ast.SelectorExpr) (string, bool) {
+	id, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	pkg := id.Name
+	if path, ok := e.pkgs[pkg]; ok {
+		pkg = path
+	}
+	return pkg + "." + sel.Sel.Name, true
+}
+
+func (e *evaluator) evalSelector(sel *ast.SelectorExpr) (evalValue, error) {
+	if name, ok := e.qualifiedIdent(sel); ok {
+		return e.resolve(name)
+	}
+	x, err := e.eval(sel.X)
+	if err != nil {
+		return evalValue{}, err
+	}
+	return e.selectField(x.v, sel.Sel.Name, e.text(sel.X))
+}
+
+// resolve looks up a package qualified name as a global or a function.
+func (e *evaluator) resolve(name string) (evalValue, error) {
+	v, err := e.d.FindGlobal(name)
+	if err == nil {
+		return evalValue{v: writable(v)}, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return evalValue{}, err
+	}
+	fn, err := e.d.FindFunc(name, false)
+	if err == nil {
+		return evalValue{v: fn, fn: name}, nil
+	}
+	if !errors.Is(err, ErrNotFound) || errors.Is(err, ErrInlined) {
+		return evalValue{}, err
+	}
+	return evalValue{}, fmt.Errorf("%s %w", name, ErrNotFound)
+}
+
+// selectField selects the field or method name of v, following pointers.
+func (e *evaluator) selectField(v reflect.Value, name, what string) (evalValue, error) {
+	if !v.IsValid() {
+		return evalValue{}, fmt.Errorf("%s has no value", what)
+	}
+	s := v
+	for s.Kind() == reflect.Ptr && !s.IsNil() {
+		s = writable(s.Elem())
+	}
+	if s.Kind() == reflect.Struct {
+		field, err := fieldByName(s, name)
+		if err == nil {
+			return evalValue{v: writable(field)}, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return evalValue{}, fmt.Errorf("%s.%s: %w", what, name, err)
+		}
+	} else if s.Kind() == reflect.Ptr {
+		return evalValue{}, fmt.Errorf("%s is nil", what)
+	}
+
+	recv := v
+	if recv.Kind() != reflect.Ptr && recv.CanAddr() {
+		recv = recv.Addr()
+	}
+	method, err := e.d.MethodByName(recv, name)
+	if err != nil {
+		if method := v.MethodByName(name); method.IsValid() {
+			return evalValue{v: method}, nil
+		}
+		return evalValue{}, fmt.Errorf("%s.%s: %w", what, name, err)
+	}
+	return evalValue{v: method}, nil
+}
+
+func (e *evaluator) evalIndex(node *ast.IndexExpr) (evalValue, error) {
+	x, err := e.eval(node.X)
+	if err != nil {
+		return evalValue{}, err
+	}
+	if !x.v.IsValid() {
+		return evalValue{}, fmt.Errorf("%s has no value", e.text(node.X))
+	}
+	index, err := e.eval(node.Index)
+	if err != nil {
+		return evalValue{}, err
+	}
+	v := x.v
+	if v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Array {
+		v = writable(v.Elem())
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		key, err := assignable(index, v.Type().Key())
+		if err != nil {
+			return evalValue{}, fmt.Errorf("index %s: %w", e.text(node.Index), err)
+		}
+		elem := v.MapIndex(key)
+		if !elem.IsValid() {
+			elem = reflect.Zero(v.Type().Elem())
+		}
+		return evalValue{v: elem}, nil
+	case reflect.Array, reflect.Slice, reflect.String:
+		i, err := intValue(index)
+		if err != nil {
+			return evalValue{}, fmt.Errorf("index %s: %w", e.text(node.Index), err)
+		}
+		if i < 0 || i >= v.Len() {
+			return evalValue{}, fmt.Errorf("index %d out of range [0:%d]", i, v.Len())
+		}
+		elem := v.Index(i)
+		if v.Kind() != reflect.String {
+			elem = writable(elem)
+		}
+		return evalValue{v: elem}, nil
+	}
+	return evalValue{}, fmt.Errorf("%s of type %s can not be indexed", e.text(node.X), v.Type())
+}
+
+func (e *evaluator) evalSlice(node *ast.SliceExpr) (evalValue, error) {
+	x, err := e.eval(node.X)
+	if err != nil {
+		return evalValue{}, err
+	}
+	if !x.v.IsValid() {
+		return evalValue{}, fmt.Errorf("%s has no value", e.text(node.X))
+	}
+	v := x.v
+	if v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Array {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice, reflect.String:
+	default:
+		return evalValue{}, fmt.Errorf("%s of type %s can not be sliced", e.text(node.X), x.v.Type())
+	}
+	bounds := [3]int{0, v.Len(), v.Cap()}
+	if v.Kind() == reflect.String {
+		bounds[2] = v.Len()
+	}
+	for i, bound := range []ast.Expr{node.Low, node.High, node.Max} {
+		if bound == nil {
+			continue
+		}
+		b, err := e.eval(bound)
+		if err != nil {
+			return evalValue{}, err
+		}
+		if bounds[i], err = intValue(b); err != nil {
+			return evalValue{}, fmt.Errorf("slice index %s: %w", e.text(bound), err)
+		}
+	}
+	if bounds[0] < 0 || bounds[0] > bounds[1] || bounds[1] > bounds[2] || bounds[2] > v.Cap() && v.Kind() != reflect.String || bounds[2] > v.Len() && v.Kind() == reflect.String {
+		return evalValue{}, fmt.Errorf("slice bounds out of range [%d:%d:%d]", bounds[0], bounds[1], bounds[2])
+	}
+	if node.Slice3 {
+		return evalValue{v: v.Slice3(bounds[0], bounds[1], bounds[2])}, nil
+	}
+	return evalValue{v: v.Slice(bounds[0], bounds[1])}, nil
+}
+
+func (e *evaluator) evalUnary(node *ast.UnaryExpr) (evalValue, error) {
+	x, err := e.eval(node.X)
+	if err != nil {
+		return evalValue{}, err
+	}
+	if node.Op == token.AND {
+		if !x.v.CanAddr() {
+			return evalValue{}, fmt.Errorf("can not take the address of %s", e.text(node.X))
+		}
+		return evalValue{v: x.v.Addr()}, nil
+	}
+	if !x.v.IsValid() {
+		return evalValue{}, fmt.Errorf("invalid operand %s", e.text(node.X))
+	}
+	v := reflect.New(x.v.Type()).Elem()
+	switch kind := x.v.Kind(); {
+	case node.Op == token.NOT && kind == reflect.Bool:
+		v.SetBool(!x.v.Bool())
+	case node.Op == token.ADD && isNumber(kind):
+		v.Set(x.v)
+	case node.Op == token.SUB && isInt(kind):
+		v.SetInt(-x.v.Int())
+	case node.Op == token.SUB && isUint(kind):
+		v.SetUint(-x.v.Uint())
+	case node.Op == token.SUB && isFloat(kind):
+		v.SetFloat(-x.v.Float())
+	case node.Op == token.SUB && isComplex(kind):
+		v.SetComplex(-x.v.Complex())
+	case node.Op == token.XOR && isInt(kind):
+		v.SetInt(^x.v.Int())
+	case node.Op == token.XOR && isUint(kind):
+		v.SetUint(^x.v.Uint())
+	default:
+		return evalValue{}, fmt.Errorf("operator %s not defined on %s", node.Op, x.v.Type())
+	}
+	return evalValue{v: v, untyped: x.untyped}, nil
+}
+
+func (e *evaluator) evalBinary(node *ast.BinaryExpr) (evalValue, error) {
+	x, err := e.eval(node.X)
+	if err != nil {
+		return evalValue{}, err
+	}
+	if node.Op == token.LAND || node.Op == token.LOR {
+		if x.v.Kind() != reflect.Bool {
+			return evalValue{}, fmt.Errorf("operator %s not defined on %s", node.Op, e.text(node.X))
+		}
+		if x.v.Bool() == (node.Op == token.LOR) {
+			return x, nil
+		}
+		y, err := e.eval(node.Y)
+		if err != nil {
+			return evalValue{}, err
+		}
+		if y.v.Kind() != reflect.Bool {
+			return evalValue{}, fmt.Errorf("operator %s not defined on %s", node.Op, e.text(node.Y))
+		}
+		return y, nil
+	}
+	y, err := e.eval(node.Y)
+	if err != nil {
+		return evalValue{}, err
+	}
+
+	// an untyped operand takes the type of the other one
+	switch {
+	case x.untyped && !y.untyped && y.v.IsValid():
+		if x.v, err = assignable(x, y.v.Type()); err != nil {
+			return evalValue{}, err
+		}
+	case y.untyped && !x.untyped && x.v.IsValid():
+		if y.v, err = assignable(y, x.v.Type()); err != nil {
+			return evalValue{}, err
+		}
+	case x.untyped && y.untyped && x.v.IsValid() && y.v.IsValid() && x.v.Type() != y.v.Type() && isNumber(x.v.Kind()) && isNumber(y.v.Kind()):
+		// mixed untyped constants use the larger kind, int < rune < float64 < complex128
+		if x.v.Kind() < y.v.Kind() {
+			x.v = x.v.Convert(y.v.Type())
+		} else {
+			y.v = y.v.Convert(x.v.Type())
+		}
+	}
+
+	switch node.Op {
+	case token.EQL, token.NEQ:
+		equal, err := evalEqual(x.v, y.v)
+		if err != nil {
+			return evalValue{}, err
+		}
+		return evalValue{v: reflect.ValueOf(equal == (node.Op == token.EQL)), untyped: true}, nil
+	}
+	if !x.v.IsValid() || !y.v.IsValid() || x.v.Type() != y.v.Type() {
+		return evalValue{}, fmt.Errorf("mismatched types in %s", e.text(node))
+	}
+	v, err := evalArith(node.Op, x.v, y.v)
+	if err != nil {
+		return evalValue{}, err
+	}
+	return evalValue{v: v, untyped: x.untyped && y.untyped}, nil
+}
+
+// evalEqual compares x and y like ==, nil is equal to nil pointers, maps, slices, funcs and interfaces.
+func evalEqual(x, y reflect.Value) (bool, error) {
+	if !x.IsValid() || !y.IsValid() {
+		if !x.IsValid() && !y.IsValid() {
+			return true, nil
+		}
+		v := x
+		if !v.IsValid() {
+			v = y
+		}
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Interface, reflect.Chan, reflect.UnsafePointer:
+			return v.IsNil(), nil
+		}
+		return false, fmt.Errorf("%s can not be compared to nil", v.Type())
+	}
+	if x.Type() != y.Type() {
+		return false, fmt.Errorf("mismatched types %s and %s", x.Type(), y.Type())
+	}
+	if !x.Type().Comparable() {
+		return false, fmt.Errorf("%s is not comparable", x.Type())
+	}
+	if x.Kind() == reflect.Interface && !x.IsNil() && !y.IsNil() && x.Elem().Type() != y.Elem().Type() {
+		return false, nil
+	}
+	for _, v := range []reflect.Value{x, y} {
+		if err := dynamicComparable(v); err != nil {
+			return false, err
+		}
+	}
+	return x.Interface() == y.Interface(), nil
+}
+
+// dynamicComparable fails when v holds an interface whose dynamic type is not
+// comparable, == panics on it.
+func dynamicComparable(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if typ := v.Elem().Type(); !typ.Comparable() {
+			return fmt.Errorf("%s is not comparable", typ)
+		}
+		return dynamicComparable(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if err := dynamicComparable(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := dynamicComparable(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// evalArith applies the arithmetic or ordering operator op to x and y of the same type.
+func evalArith(op token.Token, x, y reflect.Value) (reflect.Value, error) {
+	kind := x.Kind()
+	typ := x.Type()
+	var c int
+	v := reflect.New(typ).Elem()
+	switch {
+	case isInt(kind):
+		a, b := x.Int(), y.Int()
+		if (op == token.QUO || op == token.REM) && b == 0 {
+			return reflect.Value{}, errors.New("integer divide by zero")
+		}
+		if (op == token.SHL || op == token.SHR) && b < 0 {
+			return reflect.Value{}, fmt.Errorf("negative shift amount %d", b)
+		}
+		switch op {
+		case token.ADD:
+			v.SetInt(a + b)
+		case token.SUB:
+			v.SetInt(a - b)
+		case token.MUL:
+			v.SetInt(a * b)
+		case token.QUO:
+			v.SetInt(a / b)
+		case token.REM:
+			v.SetInt(a % b)
+		case token.AND:
+			v.SetInt(a & b)
+		case token.OR:
+			v.SetInt(a | b)
+		case token.XOR:
+			v.SetInt(a ^ b)
+		case token.SHL:
+			v.SetInt(a << uint64(b))
+		case token.SHR:
+			v.SetInt(a >> uint64(b))
+		default:
+			c = compareOrdered(a < b, a > b)
+			return orderResult(op, c, typ)
+		}
+	case isUint(kind):
+		a, b := x.Uint(), y.Uint()
+		if (op == token.QUO || op == token.REM) && b == 0 {
+			return reflect.Value{}, errors.New("integer divide by zero")
+		}
+		switch op {
+		case token.ADD:
+			v.SetUint(a + b)
+		case token.SUB:
+			v.SetUint(a - b)
+		case token.MUL:
+			v.SetUint(a * b)
+		case token.QUO:
+			v.SetUint(a / b)
+		case token.REM:
+			v.SetUint(a % b)
+		case token.AND:
+			v.SetUint(a & b)
+		case token.OR:
+			v.SetUint(a | b)
+		case token.XOR:
+			v.SetUint(a ^ b)
+		case token.SHL:
+			v.SetUint(a << b)
+		case token.SHR:
+			v.SetUint(a >> b)
+		default:
+			c = compareOrdered(a < b, a > b)
+			return orderResult(op, c, typ)
+		}
+	case isFloat(kind):
+		a, b := x.Float(), y.Float()
+		switch op {
+		case token.ADD:
+			v.SetFloat(a + b)
+		case token.SUB:
+			v.SetFloat(a - b)
+		case token.MUL:
+			v.SetFloat(a * b)
+		case token.QUO:
+			v.SetFloat(a / b)
+		default:
+			c = compareOrdered(a < b, a > b)
+			return orderResult(op, c, typ)
+		}
+	case kind == reflect.String:
+		a, b := x.String(), y.String()
+		if op == token.ADD {
+			v.SetString(a + b)
+			break
+		}
+		c = compareOrdered(a < b, a > b)
+		return orderResult(op, c, typ)
+	default:
+		return reflect.Value{}, fmt.Errorf("operator %s not defined on %s", op, typ)
+	}
+	return v, nil
+}
+
+func compareOrdered(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+func orderResult(op token.Token, c int, typ reflect.Type) (reflect.Value, error) {
+	switch op {
+	case token.LSS:
+		return reflect.ValueOf(c < 0), nil
+	case token.LEQ:
+		return reflect.ValueOf(c <= 0), nil
+	case token.GTR:
+		return reflect.ValueOf(c > 0), nil
+	case token.GEQ:
+		return reflect.ValueOf(c >= 0), nil
+	}
+	return reflect.Value{}, fmt.Errorf("operator %s not defined on %s", op, typ)
+}
+
+func (e *evaluator) evalCall(node *ast.CallExpr) (evalValue, error) {
+	if id, ok := node.Fun.(*ast.Ident); ok && (id.Name == "len" || id.Name == "cap") {
+		return e.evalLenCap(id.Name, node)
+	}
+	if typ, ok, err := e.evalType(node.Fun); ok || err != nil {
+		if err != nil {
+			return evalValue{}, err
+		}
+		return e.evalConversion(typ, node)
+	}
+
+	fn, err := e.eval(node.Fun)
+	if err != nil {
+		return evalValue{}, err
+	}
+	if fn.v.Kind() != reflect.Func {
+		return evalValue{}, fmt.Errorf("%s is not a function", e.text(node.Fun))
+	}
+	if fn.v.IsNil() {
+		return evalValue{}, fmt.Errorf("%s is nil", e.text(node.Fun))
+	}
+	args := make([]evalValue, len(node.Args))
+	for i, arg := range node.Args {
+		if args[i], err = e.eval(arg); err != nil {
+			return evalValue{}, err
+		}
+	}
+
+	ftyp := fn.v.Type()
+	if fn.fn != "" && !ftyp.IsVariadic() && ftyp.NumIn() > 0 && ftyp.In(ftyp.NumIn()-1).Kind() == reflect.Slice && !fitsParams(ftyp, args) {
+		// DWARF does not record whether a function is variadic
+		if variadic, err := e.d.FindFunc(fn.fn, true); err == nil {
+			ftyp, fn.v = variadic.Type(), variadic
+		}
+	}
+	in, err := callArgs(ftyp, args)
+	if err != nil {
+		return evalValue{}, fmt.Errorf("call %s: %w", e.text(node.Fun), err)
+	}
+	name := fn.fn
+	if name == "" {
+		name = e.text(node.Fun)
+	}
+	results, err := callRecover(name, fn.v, in)
+	if err != nil {
+		return evalValue{}, err
+	}
+	return callResult(name, results)
+}
+
+// fitsParams reports whether args match the parameters of ftyp one to one.
+func fitsParams(ftyp reflect.Type, args []evalValue) bool {
+	if len(args) != ftyp.NumIn() {
+		return false
+	}
+	_, err := callArgs(ftyp, args)
+	return err == nil
+}
+
+// callArgs converts args to the parameters of ftyp, packing the variadic ones.
+func callArgs(ftyp reflect.Type, args []evalValue) ([]reflect.Value, error) {
+	n := ftyp.NumIn()
+	if ftyp.IsVariadic() && len(args) < n-1 || !ftyp.IsVariadic() && len(args) != n {
+		return nil, fmt.Errorf("got %d arguments, expected %d", len(args), n)
+	}
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		typ := reflect.Type(nil)
+		if ftyp.IsVariadic() && i >= n-1 {
+			typ = ftyp.In(n - 1).Elem()
+		} else {
+			typ = ftyp.In(i)
+		}
+		v, err := assignable(arg, typ)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+		in[i] = v
+	}
+	return in, nil
+}
+
+// callResult returns the single result of a call, or its value and error results.
+func callResult(name string, results []reflect.Value) (evalValue, error) {
+	errorType := reflect.TypeOf((*error)(nil)).Elem()
+	switch len(results) {
+	case 0:
+		return evalValue{}, nil
+	case 1:
+		return evalValue{v: results[0]}, nil
+	case 2:
+		if results[1].Type() == errorType {
+			if !results[1].IsNil() {
+				return evalValue{}, results[1].Interface().(error)
+			}
+			return evalValue{v: results[0]}, nil
+		}
+	}
+	return evalValue{}, fmt.Errorf("%s returns %d values", name, len(results))
+}
+
+func (e *evaluator) evalLenCap(builtin string, node *ast.CallExpr) (evalValue, error) {
+	if len(node.Args) != 1 {
+		return evalValue{}, fmt.Errorf("%s takes one argument", builtin)
+	}
+	x, err := e.eval(node.Args[0])
+	if err != nil {
+		return evalValue{}, err
+	}
+	v := x.v
+	if v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Array {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Chan:
+	case reflect.Map, reflect.String:
+		if builtin == "cap" {
+			return evalValue{}, fmt.Errorf("invalid argument %s for cap", v.Type())
+		}
+	default:
+		return evalValue{}, fmt.Errorf("invalid argument %s for %s", e.text(node.Args[0]), builtin)
+	}
+	if builtin == "cap" {
+		return evalValue{v: reflect.ValueOf(v.Cap())}, nil
+	}
+	return evalValue{v: reflect.ValueOf(v.Len())}, nil
+}
+
+// evalType resolves node as a type, ok is false when it is not a type.
+func (e *evaluator) evalType(node ast.Expr) (reflect.Type, bool, error) {
+	switch n := node.(type) {
+	case *ast.ParenExpr:
+		return e.evalType(n.X)
+	case *ast.Ident:
+		typ, ok := builtinTypes[n.Name]
+		return typ, ok, nil
+	case *ast.StarExpr:
+		elem, ok, err := e.evalType(n.X)
+		if !ok || err != nil {
+			return nil, ok, err
+		}
+		return reflect.PtrTo(elem), true, nil
+	case *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType, *ast.StructType:
+		typ, err := e.d.FindType(e.text(node))
+		return typ, true, err
+	case *ast.SelectorExpr:
+		name, ok := e.qualifiedIdent(n)
+		if !ok {
+			return nil, false, nil
+		}
+		if _, err := e.d.FindGlobal(name); err == nil {
+			return nil, false, nil
+		}
+		typ, err := e.d.FindType(name)
+		if err != nil {
+			return nil, false, nil
+		}
+		return typ, true, nil
+	}
+	return nil, false, nil
+}
+
+func (e *evaluator) evalConversion(typ reflect.Type, node *ast.CallExpr) (evalValue, error) {
+	if len(node.Args) != 1 {
+		return evalValue{}, fmt.Errorf("conversion to %s takes one argument", typ)
+	}
+	x, err := e.eval(node.Args[0])
+	if err != nil {
+		return evalValue{}, err
+	}
+	if !x.v.IsValid() {
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Interface, reflect.Chan, reflect.UnsafePointer:
+			return evalValue{v: reflect.Zero(typ)}, nil
+		}
+		return evalValue{}, fmt.Errorf("can not convert nil to %s", typ)
+	}
+	if !x.v.Type().ConvertibleTo(typ) {
+		return evalValue{}, fmt.Errorf("can not convert %s to %s", x.v.Type(), typ)
+	}
+	return evalValue{v: x.v.Convert(typ)}, nil
+}
+
+// assignable returns x as a value of type typ, converting untyped constants.
+func assignable(x evalValue, typ reflect.Type) (reflect.Value, error) {
+	if !x.v.IsValid() {
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Interface, reflect.Chan, reflect.UnsafePointer:
+			return reflect.Zero(typ), nil
+		}
+		return reflect.Value{}, fmt.Errorf("can not use nil as %s", typ)
+	}
+	if x.v.Type().AssignableTo(typ) {
+		return x.v, nil
+	}
+	if x.untyped && x.v.Type().ConvertibleTo(typ) && typ.Kind() != reflect.Interface && x.v.Kind() != reflect.String == (typ.Kind() != reflect.String) {
+		v := x.v.Convert(typ)
+		if isNumber(x.v.Kind()) && !isComplex(x.v.Kind()) && !reflect.DeepEqual(v.Convert(x.v.Type()).Interface(), x.v.Interface()) {
+			return reflect.Value{}, fmt.Errorf("constant %v can not be represented as %s", x.v, typ)
+		}
+		return v, nil
+	}
+	return reflect.Value{}, fmt.Errorf("can not use %s as %s", x.v.Type(), typ)
+}
+
+// intValue returns an integer index.
+func intValue(x evalValue) (int, error) {
+	switch {
+	case !x.v.IsValid():
+	case isInt(x.v.Kind()):
+		return int(x.v.Int()), nil
+	case isUint(x.v.Kind()):
+		return int(x.v.Uint()), nil
+	}
+	return 0, errors.New("index is not an integer")
+}
+
+func isInt(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Int64
+}
+
+func isUint(kind reflect.Kind) bool {
+	return kind >= reflect.Uint && kind <= reflect.Uintptr
+}
+
+func isFloat(kind reflect.Kind) bool {
+	return kind == reflect.Float32 || kind == reflect.Float64
+}
+
+func isComplex(kind reflect.Kind) bool {
+	return kind == reflect.Complex64 || kind == reflect.Complex128
+}
+
+func isNumber(kind reflect.Kind) bool {
+	return isInt(kind) || isUint(kind) || isFloat(kind) || isComplex(kind)
+}
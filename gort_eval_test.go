@@ -0,0 +1,102 @@
+package gort_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type evalEntry struct {
+	size int
+}
+
+type evalCache struct {
+	m     map[string]*evalEntry
+	items []evalEntry
+}
+
+//go:noinline
+func (c *evalCache) Count(extra int) int {
+	return len(c.m) + extra
+}
+
+var (
+	evalName  = "gopher"
+	evalN     = 7
+	evalStore = &evalCache{m: map[string]*evalEntry{"key": {size: 42}}, items: []evalEntry{{1}, {2}, {3}}}
+	// evalIface keeps the runtime types of the methods of evalCache
+	evalIface interface{} = evalStore
+)
+
+var errEvalEmpty = errors.New("empty")
+
+//go:noinline
+func evalParse(s string) (int, error) {
+	if s == "" {
+		return 0, errEvalEmpty
+	}
+	return len(s), nil
+}
+
+func TestEval(t *testing.T) {
+	rt := newRT(t)
+	if strings.ToUpper(evalName) == "" || evalStore.Count(0) != 1 || evalN != 7 {
+		t.Fatalf("fixtures changed")
+	}
+	evalParse("")
+
+	tests := []struct {
+		expr string
+		want interface{}
+	}{
+		{testPkg + `.evalStore.m["key"].size`, 42},
+		{`strings.ToUpper(` + testPkg + `.evalName)`, "GOPHER"},
+		{`len(` + testPkg + `.evalStore.items[1:])`, 2},
+		{testPkg + `.evalStore.items[2].size * 10 + 1`, 31},
+		{testPkg + `.evalN > 5 && ` + testPkg + `.evalN < 10`, true},
+		{`float64(` + testPkg + `.evalN) / 2`, 3.5},
+		{`[]byte(` + testPkg + `.evalName)`, []byte("gopher")},
+		{`*&` + testPkg + `.evalN`, 7},
+		{`-` + testPkg + `.evalN >> 1`, -4},
+		{testPkg + `.evalStore.m["missing"] == nil`, true},
+		{testPkg + `.evalParse("abc")`, 3},
+		{testPkg + `.evalStore.Count(1)`, 2},
+	}
+	for _, test := range tests {
+		v, err := rt.Eval(test.expr)
+		if err != nil {
+			t.Errorf("eval %s err %s", test.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(v.Interface(), test.want) {
+			t.Errorf("eval %s = %#v, want %#v", test.expr, v.Interface(), test.want)
+		}
+	}
+
+	// the fields are addressable
+	v, err := rt.Eval(testPkg + `.evalStore.items[0].size`)
+	if err != nil {
+		t.Fatalf("eval err %s", err)
+	}
+	v.SetInt(5)
+	if evalStore.items[0].size != 5 {
+		t.Errorf("set through eval gave %d", evalStore.items[0].size)
+	}
+
+	if _, err := rt.Eval(testPkg + `.evalParse("")`); !errors.Is(err, errEvalEmpty) {
+		t.Errorf("eval of failing call err %v", err)
+	}
+	for _, expr := range []string{
+		testPkg + `.missing`,
+		testPkg + `.evalN + 1.5`,
+		testPkg + `.evalN << -1`,
+		testPkg + `.evalStore.items[9]`,
+		`nil[0]`,
+		testPkg + `.evalN +`,
+	} {
+		if v, err := rt.Eval(expr); err == nil {
+			t.Errorf("eval %s = %v, want an error", expr, v)
+		}
+	}
+}
@@ -4,28 +4,43 @@ import (
 	"debug/dwarf"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
+	"time"
 	"unsafe"
 
+	"github.com/go-delve/delve/pkg/dwarf/godwarf"
 	"github.com/go-delve/delve/pkg/proc"
 )
 
 func (d *DwarfRT) ForeachFunc(f func(name string, pc uint64)) error {
-	if err := d.check(); err != nil {
+	if err := d.rlock(); err != nil {
 		return err
 	}
-
+	// copy out, AddImage re-sorts bi.Functions in place
+	type funcEntry struct {
+		name  string
+		entry uint64
+	}
+	functions := make([]funcEntry, 0, len(d.bi.Functions))
 	for _, function := range d.bi.Functions {
 		if function.Entry != 0 {
-			f(function.Name, function.Entry)
+			functions = append(functions, funcEntry{function.Name, function.Entry})
 		}
 	}
+	d.mu.RUnlock()
+
+	for _, function := range functions {
+		f(function.name, function.entry)
+	}
 	return nil
 }
 
 func (d *DwarfRT) FindFuncEntry(name string) (*proc.Function, error) {
-	if err := d.check(); err != nil {
+	if err := d.rlock(); err != nil {
 		return nil, err
 	}
+	defer d.mu.RUnlock()
 
 	f, err := d.findFunc(name)
 	if err != nil {
@@ -35,9 +50,10 @@ func (d *DwarfRT) FindFuncEntry(name string) (*proc.Function, error) {
 }
 
 func (d *DwarfRT) FindFuncPc(name string) (uint64, error) {
-	if err := d.check(); err != nil {
+	if err := d.rlock(); err != nil {
 		return 0, err
 	}
+	defer d.mu.RUnlock()
 
 	f, err := d.findFunc(name)
 	if err != nil {
@@ -47,9 +63,10 @@ func (d *DwarfRT) FindFuncPc(name string) (uint64, error) {
 }
 
 func (d *DwarfRT) FindFuncType(name string, variadic bool) (reflect.Type, error) {
-	if err := d.check(); err != nil {
+	if err := d.rlock(); err != nil {
 		return nil, err
 	}
+	defer d.mu.RUnlock()
 
 	f, err := d.findFunc(name)
 	if err != nil {
@@ -65,92 +82,293 @@ func (d *DwarfRT) FindFuncType(name string, variadic bool) (reflect.Type, error)
 }
 
 func (d *DwarfRT) FindFunc(name string, variadic bool) (reflect.Value, error) {
-	pc, err := d.FindFuncPc(name)
+	if err := d.rlock(); err != nil {
+		return reflect.Value{}, err
+	}
+	defer d.mu.RUnlock()
+
+	f, err := d.findFunc(name)
 	if err != nil {
 		return reflect.Value{}, err
 	}
-	ftyp, err := d.FindFuncType(name, variadic)
+	inTyps, outTyps, _, _, err := d.getFunctionArgTypes(f)
 	if err != nil {
 		return reflect.Value{}, err
 	}
 
-	newFunc := CreateFuncForCodePtr(ftyp, pc)
+	ftyp := reflect.FuncOf(inTyps, outTyps, variadic)
+	newFunc := CreateFuncForCodePtr(ftyp, f.Entry)
 	return newFunc, nil
 }
 
+// Func returns the function name as a value of the func type T, which must
+// match the DWARF signature of the function. The returned func is called
+// directly, without reflect.Value.Call. T must agree with the binary on
+// whether the last parameter is variadic where it records it, see
+// FuncSignature.Variadic, otherwise T decides,
+// e.g. gort.Func[func(string, ...any) (int, error)](rt, "fmt.Printf").
+func Func[T any](d *DwarfRT, name string) (T, error) {
+	var zero T
+	ftyp := reflect.TypeOf((*T)(nil)).Elem()
+	if ftyp.Kind() != reflect.Func {
+		return zero, fmt.Errorf("func %s bound to non func type %s", name, ftyp)
+	}
+
+	if err := d.rlock(); err != nil {
+		return zero, err
+	}
+	f, err := d.findFunc(name)
+	if err != nil {
+		d.mu.RUnlock()
+		return zero, err
+	}
+	inTyps, outTyps, _, _, err := d.getFunctionArgTypes(f)
+	if err != nil {
+		d.mu.RUnlock()
+		return zero, err
+	}
+	variadic, known := d.funcVariadic(f, inTyps)
+	d.mu.RUnlock()
+	if !known {
+		variadic = ftyp.IsVariadic()
+	}
+
+	if variadic != ftyp.IsVariadic() || !d.signatureMatches(ftyp, inTyps, outTyps) {
+		return zero, fmt.Errorf("func %s signature %s does not match %s", name, reflect.FuncOf(inTyps, outTyps, variadic), ftyp)
+	}
+	return CreateFuncForCodePtr(ftyp, f.Entry).Interface().(T), nil
+}
+
+// signatureMatches reports whether ftyp can call a function with the
+// parameters inTyps and results outTyps. Synthesized types only need the same layout.
+func (d *DwarfRT) signatureMatches(ftyp reflect.Type, inTyps, outTyps []reflect.Type) bool {
+	if ftyp.NumIn() != len(inTyps) || ftyp.NumOut() != len(outTyps) {
+		return false
+	}
+	same := func(want, got reflect.Type) bool {
+		if want == got {
+			return true
+		}
+		return d.IsSynthesized(got) && want.Kind() == got.Kind() && want.Size() == got.Size() && want.Align() == got.Align()
+	}
+	for i, typ := range inTyps {
+		if !same(ftyp.In(i), typ) {
+			return false
+		}
+	}
+	for i, typ := range outTyps {
+		if !same(ftyp.Out(i), typ) {
+			return false
+		}
+	}
+	return true
+}
+
+// CallFunc calls the function name with args after checking their number
+// and types against its signature. A panic in the function is recovered and
+// returned as a *CallError, like the argument errors.
 func (d *DwarfRT) CallFunc(name string, variadic bool, args []reflect.Value) ([]reflect.Value, error) {
-	if err := d.check(); err != nil {
+	newFunc, err := d.prepareCall(name, variadic, args)
+	if err != nil {
 		return nil, err
 	}
-	f, err := d.findFunc(name)
+	return callRecover(name, newFunc, args)
+}
+
+// CallFuncTimeout is CallFunc running the function on its own goroutine. When
+// it does not return within timeout a *CallError wrapping ErrTimeout is
+// returned, the function keeps running as goroutines can not be stopped.
+func (d *DwarfRT) CallFuncTimeout(name string, variadic bool, args []reflect.Value, timeout time.Duration) ([]reflect.Value, error) {
+	newFunc, err := d.prepareCall(name, variadic, args)
 	if err != nil {
 		return nil, err
 	}
+	return callTimeout(name, newFunc, args, timeout)
+}
+
+// prepareCall returns the function name after checking args against its signature.
+func (d *DwarfRT) prepareCall(name string, variadic bool, args []reflect.Value) (reflect.Value, error) {
+	if err := d.rlock(); err != nil {
+		return reflect.Value{}, err
+	}
+	f, err := d.findFunc(name)
+	if err != nil {
+		d.mu.RUnlock()
+		return reflect.Value{}, err
+	}
 
 	inTyps, outTyps, inNames, _, err := d.getFunctionArgTypes(f)
+	d.mu.RUnlock()
 	if err != nil {
-		return nil, err
+		return reflect.Value{}, err
+	}
+
+	if err := checkCallArgs(name, inTyps, inNames, variadic, args); err != nil {
+		return reflect.Value{}, err
 	}
 
 	ftyp := reflect.FuncOf(inTyps, outTyps, variadic)
-	newFunc := CreateFuncForCodePtr(ftyp, f.Entry)
+	return CreateFuncForCodePtr(ftyp, f.Entry), nil
+}
 
-	getInTyp := func(i int) (reflect.Type, string) {
-		if len(inTyps) <= 0 {
-			return nil, ""
-		}
-		if i < len(inTyps)-1 {
-			return inTyps[i], inNames[i]
-		}
-		if variadic {
-			return inTyps[len(inTyps)-1].Elem(), inNames[len(inNames)-1]
-		}
-		if i < len(inTyps) {
-			return inTyps[i], inNames[i]
-		}
-		return nil, ""
+func notVariadicError(name string) error {
+	return &CallError{Func: name, Arg: -1, Err: fmt.Errorf("last parameter is not a slice, can not be variadic")}
+}
+
+// checkCallArgs checks args can be passed to a function with parameters inTyps.
+func checkCallArgs(name string, inTyps []reflect.Type, inNames []string, variadic bool, args []reflect.Value) error {
+	if variadic && (len(inTyps) == 0 || inTyps[len(inTyps)-1].Kind() != reflect.Slice) {
+		return notVariadicError(name)
+	}
+	required := len(inTyps)
+	if variadic {
+		required--
+	}
+	if len(args) < required || (!variadic && len(args) > required) {
+		return &CallError{Func: name, Arg: -1, Err: fmt.Errorf("len mismatch %d, expected %d", len(args), required)}
 	}
 
 	for i, arg := range args {
-		inTyp, inName := getInTyp(i)
-		if inTyp == nil {
-			return nil, fmt.Errorf("len mismatch %d", i)
+		inTyp, inName := inTyps[len(inTyps)-1], inNames[len(inNames)-1]
+		if i < required {
+			inTyp, inName = inTyps[i], inNames[i]
+		} else {
+			inTyp = inTyp.Elem()
 		}
 
+		if !arg.IsValid() {
+			return &CallError{Func: name, Arg: i, Err: fmt.Errorf("invalid value for %s", inName)}
+		}
 		if !arg.Type().AssignableTo(inTyp) {
-			return nil, fmt.Errorf("type mismatch %d:%s", i, inName)
+			return &CallError{Func: name, Arg: i, Err: fmt.Errorf("type mismatch %d:%s, %s is not assignable to %s", i, inName, arg.Type(), inTyp)}
 		}
 	}
-
-	out := newFunc.Call(args)
-	return out, nil
+	return nil
 }
 
+// findFunc looks up name, which may be qualified by an image as in
+// "libfoo.so:pkg.F". Unqualified names prefer the main executable.
 func (d *DwarfRT) findFunc(name string) (*proc.Function, error) {
-	for i := len(d.bi.Functions) - 1; i >= 0; i-- {
-		if d.bi.Functions[i].Name == name {
-			if d.bi.Functions[i].Entry != 0 {
-				return &(d.bi.Functions[i]), nil
-			}
-			return nil, ErrNotFound
+	img, name := d.splitImage(name)
+	return d.findFuncIn(img, name)
+}
+
+// findFuncIn looks up name in img, or in any image when img is nil, falling
+// back to vendored copies of the function.
+func (d *DwarfRT) findFuncIn(img *proc.Image, name string) (*proc.Function, error) {
+	if strings.Contains(name, "[") {
+		name = normalizeInstantiations(name)
+		if dwarfName, ok := d.loadGenerics().funcs[name]; ok {
+			name = dwarfName
+		}
+	}
+	match := func(fn string) bool { return fn == name }
+	f, err := d.selectFunc(img, name, match)
+	if err == ErrNotFound && img == nil && strings.Contains(name, ".") {
+		vendored := func(fn string) bool {
+			return strings.HasSuffix(fn, "/vendor/"+name) || fn == "vendor/"+name
+		}
+		f, err = d.selectFunc(img, name, vendored)
+		if err == ErrNotFound {
+			match = func(fn string) bool { return fn == name || vendored(fn) }
+		}
+	}
+	if err == ErrNotFound {
+		if inlined := d.inlinedError(img, name, match); inlined != nil {
+			return nil, inlined
 		}
 	}
-	return nil, ErrNotFound
+	return f, err
+}
+
+// selectFunc returns the function matching match in img, or in any image when
+// img is nil, the main executable wins over other images.
+func (d *DwarfRT) selectFunc(img *proc.Image, name string, match func(string) bool) (*proc.Function, error) {
+	var candidates []*proc.Function
+	for i := range d.bi.Functions {
+		fn := &d.bi.Functions[i]
+		if fn.Entry == 0 || !match(fn.Name) {
+			continue
+		}
+		fnImage := functionImage(fn)
+		if img != nil && fnImage != img {
+			continue
+		}
+		if img == nil && fnImage == d.bi.Images[0] {
+			candidates = []*proc.Function{fn}
+			break
+		}
+		candidates = append(candidates, fn)
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, ErrNotFound
+	case 1:
+		// copy, AddImage re-sorts bi.Functions in place
+		f := *candidates[0]
+		return &f, nil
+	}
+	err := &AmbiguousError{Name: name}
+	for _, fn := range candidates {
+		err.Candidates = append(err.Candidates, qualifiedName(functionImage(fn), fn.Name))
+	}
+	sort.Strings(err.Candidates)
+	return nil, err
 }
 
 func (d *DwarfRT) getFunctionArgTypes(f *proc.Function) ([]reflect.Type, []reflect.Type, []string, []string, error) {
+	params, err := d.funcParams(f)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	var inTyps []reflect.Type
+	var outTyps []reflect.Type
+	var inNames []string
+	var outNames []string
+
+	for _, param := range params {
+		rtyp, err := d.lookupType(param.typeName)
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("get function arg types type err %s:%s", f.Name, err.Error())
+		}
+		if param.ret {
+			outTyps = append(outTyps, rtyp)
+			outNames = append(outNames, param.name)
+		} else {
+			inTyps = append(inTyps, rtyp)
+			inNames = append(inNames, param.name)
+		}
+	}
+	return inTyps, outTyps, inNames, outNames, nil
+}
+
+// dwarfParam is a formal parameter from the DWARF entry of a function.
+type dwarfParam struct {
+	name     string
+	typeName string
+	ret      bool
+	// variadic is set on the last input parameter when the entry has
+	// DW_TAG_unspecified_parameters
+	variadic bool
+	// dictIndex is the dictionary entry holding the runtime type of a
+	// parameter of a shape function, -1 for other parameters
+	dictIndex int
+}
+
+func (d *DwarfRT) funcParams(f *proc.Function) ([]dwarfParam, error) {
 	rOffset := reflect.ValueOf(f).Elem().FieldByName("offset")
 	rCU := reflect.ValueOf(f).Elem().FieldByName("cu")
 	if !rOffset.IsValid() || !rCU.IsValid() {
-		return nil, nil, nil, nil, ErrNotSupport
+		return nil, ErrNotSupport
 	}
 	rImage := rCU.Elem().FieldByName("image")
 	if !rImage.IsValid() {
-		return nil, nil, nil, nil, ErrNotSupport
+		return nil, ErrNotSupport
 	}
 	rDwarf := rImage.Elem().FieldByName("dwarf")
 	if !rDwarf.IsValid() {
-		return nil, nil, nil, nil, ErrNotSupport
+		return nil, ErrNotSupport
 	}
 	image := (*proc.Image)(unsafe.Pointer(rImage.Pointer()))
 	dwarfData := (*dwarf.Data)(unsafe.Pointer(rDwarf.Pointer()))
@@ -159,48 +377,55 @@ func (d *DwarfRT) getFunctionArgTypes(f *proc.Function) ([]reflect.Type, []refle
 	reader.Seek(dwarf.Offset(rOffset.Uint()))
 	entry, err := reader.Next()
 	if err != nil || entry == nil || entry.Tag != dwarf.TagSubprogram {
-		return nil, nil, nil, nil, fmt.Errorf("get function arg types not found %s", f.Name)
+		return nil, fmt.Errorf("get function arg types not found %s", f.Name)
 	}
 	name, ok := entry.Val(dwarf.AttrName).(string)
 	if !ok || f.Name != name {
-		return nil, nil, nil, nil, fmt.Errorf("get function arg types name err %s:%s", f.Name, name)
+		return nil, fmt.Errorf("get function arg types name err %s:%s", f.Name, name)
 	}
 
-	var inTyps []reflect.Type
-	var outTyps []reflect.Type
-	var inNames []string
-	var outNames []string
-
+	var params []dwarfParam
 	for {
 		child, err := reader.Next()
 		if err != nil {
-			return nil, nil, nil, nil, fmt.Errorf("get function arg types reader err %s:%s", f.Name, err.Error())
+			return nil, fmt.Errorf("get function arg types reader err %s:%s", f.Name, err.Error())
 		}
 		if child == nil || child.Tag == 0 {
 			break
 		}
+		if child.Tag == dwarf.TagUnspecifiedParameters {
+			for i := len(params) - 1; i >= 0; i-- {
+				if !params[i].ret {
+					params[i].variadic = true
+					break
+				}
+			}
+		}
 		if child.Tag != dwarf.TagFormalParameter {
+			// lexical blocks and inlined calls have their own parameters
+			if child.Children {
+				reader.SkipChildren()
+			}
 			continue
 		}
 
-		dtyp, err := entryType(dwarfData, child)
+		dtyp, err := d.entryType(dwarfData, child)
 		if err != nil {
-			return nil, nil, nil, nil, fmt.Errorf("get function arg types type err %s:%s", f.Name, err.Error())
+			return nil, fmt.Errorf("get function arg types type err %s:%s", f.Name, err.Error())
 		}
-		dname := dwarfTypeName(dtyp)
-		rtyp, err := d.FindType(dname)
-		if err != nil {
-			return nil, nil, nil, nil, fmt.Errorf("get function arg types type err %s:%s", f.Name, err.Error())
-		}
-
+		pname, _ := child.Val(dwarf.AttrName).(string)
 		isret, _ := child.Val(dwarf.AttrVarParam).(bool)
-		if isret {
-			outTyps = append(outTyps, rtyp)
-			outNames = append(outNames, dname)
-		} else {
-			inTyps = append(inTyps, rtyp)
-			inNames = append(inNames, dname)
+		param := dwarfParam{name: pname, typeName: dwarfTypeName(dtyp), ret: isret, dictIndex: -1}
+		if typedef, ok := dtyp.(*dwarf.TypedefType); ok && strings.HasPrefix(typedef.Name, ".param") {
+			typeReader := image.DwarfReader()
+			typeReader.Seek(child.Val(dwarf.AttrType).(dwarf.Offset))
+			if typeEntry, err := typeReader.Next(); err == nil && typeEntry != nil {
+				if index, ok := typeEntry.Val(godwarf.AttrGoDictIndex).(int64); ok {
+					param.dictIndex = int(index)
+				}
+			}
 		}
+		params = append(params, param)
 	}
-	return inTyps, outTyps, inNames, outNames, nil
+	return params, nil
 }
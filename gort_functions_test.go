@@ -0,0 +1,77 @@
+package gort_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lsg2020/gort"
+)
+
+type joiner struct {
+	n int
+}
+
+//go:noinline
+func (j *joiner) Join(sep string, parts ...string) string {
+	return fmt.Sprint(j.n) + ":" + strings.Join(parts, sep)
+}
+
+//go:noinline
+func (j *joiner) Split(s string, seps []string) int {
+	return len(seps)
+}
+
+//go:noinline
+func joinPair(a, b string) string {
+	return a + b
+}
+
+// joinerIface keeps the runtime types of the methods of joiner
+var joinerIface interface{} = &joiner{}
+
+func TestFunc(t *testing.T) {
+	rt := newRT(t)
+	if joinPair("a", "b") != "ab" {
+		t.Fatalf("fixtures changed")
+	}
+
+	pair, err := gort.Func[func(string, string) string](rt, testPkg+".joinPair")
+	if err != nil {
+		t.Fatalf("func joinPair err %s", err)
+	}
+	if s := pair("x", "y"); s != "xy" {
+		t.Errorf("joinPair returned %q", s)
+	}
+
+	join, err := gort.Func[func(*joiner, string, ...string) string](rt, testPkg+".(*joiner).Join")
+	if err != nil {
+		t.Fatalf("func Join err %s", err)
+	}
+	if s := join(&joiner{1}, "-", "a", "b"); s != "1:a-b" {
+		t.Errorf("Join returned %q", s)
+	}
+
+	// the binary records the variadic flag of exported methods
+	if _, err := gort.Func[func(*joiner, string, []string) string](rt, testPkg+".(*joiner).Join"); err == nil {
+		t.Errorf("slice func type for a variadic method succeeded")
+	}
+	if _, err := gort.Func[func(*joiner, string, ...string) int](rt, testPkg+".(*joiner).Split"); err == nil {
+		t.Errorf("variadic func type for a slice parameter succeeded")
+	}
+	// functions do not record it, the func type decides
+	printf, err := gort.Func[func(string, ...interface{}) string](rt, "fmt.Sprintf")
+	if err != nil {
+		t.Fatalf("func fmt.Sprintf err %s", err)
+	}
+	if s := printf("%d-%s", 1, "a"); s != "1-a" {
+		t.Errorf("Sprintf returned %q", s)
+	}
+
+	if _, err := gort.Func[func(int, string) string](rt, testPkg+".joinPair"); err == nil {
+		t.Errorf("mismatched func type succeeded")
+	}
+	if _, err := gort.Func[int](rt, testPkg+".joinPair"); err == nil {
+		t.Errorf("non func type succeeded")
+	}
+}
@@ -0,0 +1,198 @@
+package gort
+
+import (
+	"sort"
+	"strings"
+)
+
+// Instantiation describes one instantiation of a generic type or function.
+type Instantiation struct {
+	Name     string   // DWARF name, e.g. "main.List[int]" or "main.(*List[go.shape.int]).Push"
+	TypeArgs []string // type arguments in declaration order
+	Func     bool     // true for functions and methods, false for types
+	Shape    bool     // true for the shape stenciled code shared by several instantiations
+}
+
+// genericIndex maps generic names without type arguments to their instantiations.
+type genericIndex struct {
+	insts map[string][]Instantiation
+	// funcs maps normalized function names to DWARF names when they differ
+	funcs map[string]string
+}
+
+// Instantiations lists the instantiations of the generic type or function name,
+// e.g. "main.List", "main.Map" or the method "main.(*List).Push".
+func (d *DwarfRT) Instantiations(name string) ([]Instantiation, error) {
+	if err := d.rlock(); err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	insts := d.loadGenerics().insts[stripTypeArgs(name)]
+	if len(insts) == 0 {
+		return nil, ErrNotFound
+	}
+	return append([]Instantiation(nil), insts...), nil
+}
+
+func (d *DwarfRT) loadGenerics() *genericIndex {
+	snap := d.snap
+	snap.genericsOnce.Do(func() {
+		snap.generics = d.buildGenerics()
+	})
+	return snap.generics
+}
+
+func (d *DwarfRT) buildGenerics() *genericIndex {
+	idx := &genericIndex{
+		insts: make(map[string][]Instantiation),
+		funcs: make(map[string]string),
+	}
+	add := func(name string, isFunc bool) {
+		if strings.HasPrefix(name, "type:") || strings.HasPrefix(name, "type..") {
+			return
+		}
+		args := typeArgs(name)
+		if args == nil {
+			return
+		}
+		inst := Instantiation{Name: name, TypeArgs: args, Func: isFunc}
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "go.shape.") {
+				inst.Shape = true
+			}
+		}
+		base := stripTypeArgs(name)
+		idx.insts[base] = append(idx.insts[base], inst)
+	}
+
+	types, _ := d.bi.Types()
+	for _, name := range types {
+		add(name, false)
+	}
+	for _, fn := range d.bi.Functions {
+		if fn.Entry == 0 || !strings.Contains(fn.Name, "[") {
+			continue
+		}
+		add(fn.Name, true)
+		if normalized := normalizeInstantiations(fn.Name); normalized != fn.Name {
+			idx.funcs[normalized] = fn.Name
+		}
+	}
+	for _, insts := range idx.insts {
+		sort.Slice(insts, func(i, j int) bool { return insts[i].Name < insts[j].Name })
+	}
+	return idx
+}
+
+// normalizeTypeName rewrites a type name to the spelling used in DWARF,
+// e.g. "main.Pair[string, interface{}]" to "main.Pair[string,interface {}]".
+func normalizeTypeName(name string) string {
+	expr, err := parseTypeExpr(name)
+	if err != nil {
+		return normalizeInstantiations(name)
+	}
+	return expr.String()
+}
+
+// normalizeInstantiations normalizes the type argument lists of the
+// instantiations in name, which may be a type or function name.
+func normalizeInstantiations(name string) string {
+	if !strings.Contains(name, "[") {
+		return name
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c != '[' || i == 0 || !isNameByte(name[i-1]) || isMapBracket(name, i) {
+			b.WriteByte(c)
+			continue
+		}
+		end := matchingBracket(name, i)
+		if end < 0 {
+			b.WriteString(name[i:])
+			break
+		}
+		args := splitTypeArgs(name[i+1 : end])
+		for j := range args {
+			args[j] = normalizeTypeName(args[j])
+		}
+		b.WriteString("[" + strings.Join(args, ",") + "]")
+		i = end
+	}
+	return b.String()
+}
+
+// isMapBracket reports whether the '[' at i opens the key of a map type,
+// rather than the type arguments of a name ending in "map" such as main.bitmap.
+func isMapBracket(name string, i int) bool {
+	return strings.HasSuffix(name[:i], "map") && (i == 3 || !isNameByte(name[i-4]))
+}
+
+// typeArgs returns the type arguments of the first instantiation in name, or nil.
+func typeArgs(name string) []string {
+	for i := 1; i < len(name); i++ {
+		if name[i] != '[' || !isNameByte(name[i-1]) || isMapBracket(name, i) {
+			continue
+		}
+		end := matchingBracket(name, i)
+		if end < 0 {
+			return nil
+		}
+		return splitTypeArgs(name[i+1 : end])
+	}
+	return nil
+}
+
+// stripTypeArgs removes the type argument lists from name,
+// "main.(*List[int]).Push" becomes "main.(*List).Push".
+func stripTypeArgs(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c == '[' && i > 0 && isNameByte(name[i-1]) && !isMapBracket(name, i) {
+			if end := matchingBracket(name, i); end >= 0 {
+				i = end
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func matchingBracket(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTypeArgs splits a type argument list at its top level commas.
+func splitTypeArgs(s string) []string {
+	var args []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '[', '(', '{':
+			depth++
+		case ']', ')', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(args, strings.TrimSpace(s[start:]))
+}
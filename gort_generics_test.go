@@ -0,0 +1,106 @@
+package gort_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type genList[T any] struct {
+	items []T
+}
+
+//go:noinline
+func (l *genList[T]) Push(v T) {
+	l.items = append(l.items, v)
+}
+
+type genPair[K comparable, V any] struct {
+	k K
+	v V
+}
+
+type genT struct {
+	x int
+}
+
+//go:noinline
+func genMap[A, B any](in []A, f func(A) B) []B {
+	out := make([]B, 0, len(in))
+	for _, v := range in {
+		out = append(out, f(v))
+	}
+	return out
+}
+
+var (
+	genInts  = &genList[int]{}
+	genPairT = genPair[string, *genT]{k: "a"}
+)
+
+func TestGenericTypes(t *testing.T) {
+	rt := newRT(t)
+	genInts.Push(1)
+	genMap([]int{1}, func(i int) string { return fmt.Sprint(i) })
+
+	tests := []struct {
+		name string
+		want reflect.Type
+	}{
+		{testPkg + ".genList[int]", reflect.TypeOf(genList[int]{})},
+		// type arguments are normalized
+		{testPkg + ".genPair[string, *" + testPkg + ".genT]", reflect.TypeOf(genPairT)},
+		{"[]" + testPkg + ".genList[ int ]", reflect.TypeOf([]genList[int](nil))},
+		{"map[string]*" + testPkg + ".genList[int]", reflect.TypeOf(map[string]*genList[int](nil))},
+	}
+	for _, test := range tests {
+		typ, err := rt.FindType(test.name)
+		if err != nil {
+			t.Errorf("find type %s err %s", test.name, err)
+			continue
+		}
+		if typ != test.want {
+			t.Errorf("find type %s got %s, want %s", test.name, typ, test.want)
+		}
+	}
+}
+
+func TestInstantiations(t *testing.T) {
+	rt := newRT(t)
+	genInts.Push(1)
+	genMap([]int{1}, func(i int) string { return fmt.Sprint(i) })
+
+	insts, err := rt.Instantiations(testPkg + ".genList")
+	if err != nil {
+		t.Fatalf("instantiations err %s", err)
+	}
+	found := false
+	for _, inst := range insts {
+		if inst.Name == testPkg+".genList[int]" {
+			found = true
+			if inst.Func || inst.Shape || len(inst.TypeArgs) != 1 || inst.TypeArgs[0] != "int" {
+				t.Errorf("instantiation %+v", inst)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("genList[int] not in %+v", insts)
+	}
+
+	insts, err = rt.Instantiations(testPkg + ".genMap")
+	if err != nil {
+		t.Fatalf("function instantiations err %s", err)
+	}
+	for _, inst := range insts {
+		if !inst.Func || !inst.Shape || len(inst.TypeArgs) != 2 {
+			t.Errorf("function instantiation %+v", inst)
+		}
+	}
+	if _, err := rt.Instantiations(testPkg + ".genT"); err == nil {
+		t.Errorf("instantiations of a non generic type succeeded")
+	}
+
+	if _, err := rt.FindFuncPc(testPkg + ".(*genList[ int ]).Push"); err != nil {
+		t.Errorf("find method of instantiation err %s", err)
+	}
+}
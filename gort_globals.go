@@ -1,92 +1,407 @@
 package gort
 
 import (
+	"bytes"
 	"debug/dwarf"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"unsafe"
 
 	"github.com/go-delve/delve/pkg/proc"
 )
 
+// ForeachGlobal calls f with every global, the globals of images added by
+// AddImage are qualified with the image, e.g. "libfoo.so:pkg.V".
 func (d *DwarfRT) ForeachGlobal(f func(name string, v reflect.Value)) error {
-	if err := d.check(); err != nil {
+	if err := d.rlock(); err != nil {
 		return err
 	}
-	if d.globals == nil {
-		d.loadGlobals()
-	}
+	globals := d.loadGlobals()
+	d.mu.RUnlock()
 
-	for name, v := range d.globals {
+	for name, v := range globals {
 		f(name, v)
 	}
 	return nil
 }
 
+// FindGlobal returns the global name, which may be qualified with an image.
+// Unqualified names prefer the executable, a name defined only by several
+// other images is ambiguous.
 func (d *DwarfRT) FindGlobal(name string) (reflect.Value, error) {
-	if err := d.check(); err != nil {
+	if err := d.rlock(); err != nil {
+		return reflect.Value{}, err
+	}
+	defer d.mu.RUnlock()
+
+	return d.findGlobal(name)
+}
+
+// findGlobal looks up the global name. d.mu must be held.
+func (d *DwarfRT) findGlobal(name string) (reflect.Value, error) {
+	globals := d.loadGlobals()
+	key, err := d.globalKey(name)
+	if err != nil {
 		return reflect.Value{}, err
 	}
-	if d.globals == nil {
-		d.loadGlobals()
+	if v, ok := globals[key]; ok {
+		return v, nil
+	}
+	if v, ok := d.snap.globalVars[key]; ok && v.err != nil {
+		return reflect.Value{}, v.diagnostic()
+	}
+	return reflect.Value{}, ErrNotFound
+}
+
+// globalKey returns the key of the global name in the globals of the
+// snapshot, qualified for the globals of other images. d.mu must be held.
+func (d *DwarfRT) globalKey(name string) (string, error) {
+	if img, local := d.splitImage(name); img != nil {
+		if img != d.bi.Images[0] {
+			local = qualifiedName(img, local)
+		}
+		return local, nil
+	}
+	if _, ok := d.snap.globalVars[name]; ok {
+		return name, nil
 	}
+	switch candidates := d.snap.globalImages[name]; len(candidates) {
+	case 0:
+		return name, nil
+	case 1:
+		return candidates[0], nil
+	default:
+		return "", &AmbiguousError{Name: name, Candidates: candidates}
+	}
+}
+
+// GlobalDiagnostic tells why a package level variable found in DWARF is not
+// available through FindGlobal, FindGlobal returns it as the error.
+type GlobalDiagnostic struct {
+	Name     string
+	Addr     uint64 // 0 when the variable has no static address
+	TypeName string // DWARF type name, empty when the type could not be read
+	Err      error
+}
+
+func (e *GlobalDiagnostic) Error() string {
+	if e.TypeName == "" {
+		return fmt.Sprintf("global %s: %s", e.Name, e.Err)
+	}
+	return fmt.Sprintf("global %s of type %s: %s", e.Name, e.TypeName, e.Err)
+}
+
+func (e *GlobalDiagnostic) Unwrap() error {
+	return e.Err
+}
+
+func (e *GlobalDiagnostic) Is(target error) bool {
+	return target == ErrNotFound
+}
 
-	v, ok := d.globals[name]
+// GlobalDiagnostics returns the package level variables that were skipped,
+// sorted by name, with the reason each one was skipped. The error tells the
+// variables of the executable could not be read from delve, the diagnostics
+// of the other images are returned with it.
+func (d *DwarfRT) GlobalDiagnostics() ([]*GlobalDiagnostic, error) {
+	if err := d.rlock(); err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	d.loadGlobals()
+	var diags []*GlobalDiagnostic
+	for _, v := range d.snap.globalVars {
+		if v.err != nil {
+			diags = append(diags, v.diagnostic())
+		}
+	}
+	sort.Slice(diags, func(i, j int) bool { return diags[i].Name < diags[j].Name })
+	return diags, d.snap.globalsErr
+}
+
+// RawGlobal is the memory of a package level variable with its DWARF type,
+// it is available for variables FindGlobal skips.
+type RawGlobal struct {
+	Name      string
+	Addr      uint64
+	TypeName  string
+	DwarfType dwarf.Type
+	Mem       []byte // DwarfType.Size() bytes at Addr, writes go to the variable
+	Err       error  // why FindGlobal skips the variable, nil when it does not
+}
+
+// FindRawGlobal returns the memory and DWARF type of the global name, whether
+// or not FindGlobal can build a reflect.Value for it.
+func (d *DwarfRT) FindRawGlobal(name string) (*RawGlobal, error) {
+	if err := d.rlock(); err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	d.loadGlobals()
+	key, err := d.globalKey(name)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := d.snap.globalVars[key]
 	if !ok {
-		return reflect.Value{}, ErrNotFound
+		return nil, ErrNotFound
 	}
-	return v, nil
+	raw := &RawGlobal{Name: key, Addr: v.addr, TypeName: v.typeName, DwarfType: v.dtyp, Mem: []byte{}}
+	if v.err != nil {
+		raw.Err = v.diagnostic()
+	}
+	if v.addr == 0 || v.dtyp == nil || v.dtyp.Size() < 0 {
+		if raw.Err != nil {
+			return nil, fmt.Errorf("global %s has no memory: %w", key, raw.Err)
+		}
+		return nil, fmt.Errorf("global %s has no memory", key)
+	}
+	if v.dtyp.Size() > 0 {
+		raw.Mem = entryAddress(uintptr(v.addr), int(v.dtyp.Size()))
+	}
+	return raw, nil
 }
 
-func (d *DwarfRT) loadGlobals() {
-	d.globals = make(map[string]reflect.Value)
+// globalVar is a package level variable read from DWARF, err tells why it
+// can not be used, the fields after name may be unset then.
+type globalVar struct {
+	img      *proc.Image
+	name     string
+	addr     uint64
+	typeName string
+	dtyp     dwarf.Type
+	err      error
+}
 
-	packageVars := reflect.ValueOf(d.bi).Elem().FieldByName("packageVars")
-	if packageVars.IsValid() {
-		for i := 0; i < packageVars.Len(); i++ {
-			rv := packageVars.Index(i)
-			rName := rv.FieldByName("name")
-			rAddr := rv.FieldByName("addr")
-			rOffset := rv.FieldByName("offset")
-			rCU := rv.FieldByName("cu")
-			if !rName.IsValid() || !rAddr.IsValid() || !rCU.IsValid() || !rOffset.IsValid() {
-				continue
-			}
-			rImage := rCU.Elem().FieldByName("image")
-			if !rImage.IsValid() {
-				continue
-			}
-			rDwarf := rImage.Elem().FieldByName("dwarf")
-			if !rDwarf.IsValid() {
-				continue
+func (v *globalVar) diagnostic() *GlobalDiagnostic {
+	return &GlobalDiagnostic{Name: v.name, Addr: v.addr, TypeName: v.typeName, Err: v.err}
+}
+
+// loadGlobals returns the globals of the current snapshot, building them on first use.
+func (d *DwarfRT) loadGlobals() map[string]reflect.Value {
+	snap := d.snap
+	snap.globalsOnce.Do(func() {
+		d.buildGlobals(snap)
+	})
+	return snap.globals
+}
+
+func (d *DwarfRT) buildGlobals(snap *snapshot) {
+	snap.globals = make(map[string]reflect.Value)
+	snap.globalImages = make(map[string][]string)
+	snap.globalVars = make(map[string]*globalVar)
+	snap.globalsErr = d.packageVars(func(v *globalVar) {
+		isExe := v.img == nil || v.img == d.bi.Images[0]
+		if !isExe {
+			v.name = qualifiedName(v.img, v.name)
+		}
+		if _, exists := snap.globalVars[v.name]; exists {
+			return
+		}
+		snap.globalVars[v.name] = v
+		if v.err != nil {
+			return
+		}
+
+		var rtyp reflect.Type
+		var err error
+		if isExe {
+			rtyp, err = d.lookupType(v.typeName)
+		} else if rtyp, err = d.lookupType(qualifiedName(v.img, v.typeName)); err != nil {
+			// plugin types resolve in their image first, basic types may only be found in the executable
+			rtyp, err = d.lookupType(v.typeName)
+		}
+		if err == nil && v.dtyp.Size() >= 0 && int64(rtyp.Size()) != v.dtyp.Size() {
+			err = fmt.Errorf("%s has size %d, DWARF says %d", rtyp, rtyp.Size(), v.dtyp.Size())
+		}
+		if err != nil {
+			v.err = fmt.Errorf("resolve type %s: %w", v.typeName, err)
+			return
+		}
+		snap.globals[v.name] = reflect.NewAt(rtyp, addrPointer(v.addr)).Elem()
+		if !isExe {
+			_, local := d.splitImage(v.name)
+			snap.globalImages[local] = append(snap.globalImages[local], v.name)
+		}
+	})
+}
+
+// packageVars calls f with the package level variables, the skipped ones
+// included with the reason. The variables of the executable come from delve,
+// which drops variables of other images whose name it has already seen, so
+// the DWARF of the other images is read here. The error tells the variables
+// of the executable could not be read, those of the other images still are.
+func (d *DwarfRT) packageVars(f func(v *globalVar)) error {
+	exePkgs := make(map[string]bool)
+	err := d.exeVars(func(v *globalVar) {
+		pkg, _ := splitPackage(v.name)
+		exePkgs[pkg] = true
+		f(v)
+	})
+
+	// Other images link their own copy of the packages they depend on, the
+	// runtime and the packages shared with the executable included. The dynamic
+	// linker binds the variables an image loaded before exports to the first
+	// definition, so the later copies are never used and are left out.
+	exported := dynamicObjects(d.bi.Images[0])
+	for _, img := range d.bi.Images[1:] {
+		d.imageVars(img, func(v *globalVar) {
+			name := strings.TrimPrefix(v.name, "C.")
+			if exported != nil && exported[name] {
+				return
 			}
-			image := (*proc.Image)(unsafe.Pointer(rImage.Pointer()))
-			dwarfData := (*dwarf.Data)(unsafe.Pointer(rDwarf.Pointer()))
-
-			reader := image.DwarfReader()
-			reader.Seek(dwarf.Offset(rOffset.Uint()))
-			entry, err := reader.Next()
-			if err != nil || entry == nil || entry.Tag != dwarf.TagVariable {
-				continue
+			if pkg, _ := splitPackage(v.name); exported == nil && exePkgs[pkg] {
+				return
 			}
-			name, ok := entry.Val(dwarf.AttrName).(string)
-			if !ok || rName.String() != name {
-				continue
+			f(v)
+		})
+		if exported != nil {
+			for name := range dynamicObjects(img) {
+				exported[name] = true
 			}
+		}
+	}
+	return err
+}
 
-			dtyp, err := entryType(dwarfData, entry)
-			if err != nil {
-				continue
-			}
-			dname := dwarfTypeName(dtyp)
-			if dname == "<unspecified>" || dname == "" {
-				continue
+// exeVars calls f with the package level variables of the executable loaded by delve.
+func (d *DwarfRT) exeVars(f func(v *globalVar)) error {
+	packageVars := reflect.ValueOf(d.bi).Elem().FieldByName("packageVars")
+	if !packageVars.IsValid() {
+		return fmt.Errorf("delve BinaryInfo has no packageVars: %w", ErrNotSupport)
+	}
+	for i := 0; i < packageVars.Len(); i++ {
+		rv := packageVars.Index(i)
+		rName := rv.FieldByName("name")
+		rAddr := rv.FieldByName("addr")
+		rOffset := rv.FieldByName("offset")
+		rCU := rv.FieldByName("cu")
+		if !rName.IsValid() || !rAddr.IsValid() || !rCU.IsValid() || !rOffset.IsValid() {
+			return fmt.Errorf("delve packageVar layout: %w", ErrNotSupport)
+		}
+		v := &globalVar{name: rName.String(), addr: rAddr.Uint()}
+		image, dwarfData, _ := compileUnitOf(rCU)
+		switch {
+		case image == nil:
+			v.err = fmt.Errorf("compile unit has no image: %w", ErrNotSupport)
+		case image != d.bi.Images[0]:
+			continue
+		default:
+			v.img = image
+			d.resolveVar(v, image, dwarfData, dwarf.Offset(rOffset.Uint()))
+			if v.err == nil && v.addr == image.StaticBase {
+				v.err = errors.New("no static address")
+				v.addr = 0
 			}
+		}
+		f(v)
+	}
+	return nil
+}
+
+// dynamicObjects returns the names of the data objects img exports to the
+// dynamic linker, or nil when its dynamic symbols can not be read.
+func dynamicObjects(img *proc.Image) map[string]bool {
+	f, err := elf.Open(img.Path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	symbols, err := f.DynamicSymbols()
+	if err != nil {
+		return nil
+	}
+	names := make(map[string]bool)
+	for _, sym := range symbols {
+		if elf.ST_TYPE(sym.Info) == elf.STT_OBJECT && sym.Section != elf.SHN_UNDEF {
+			names[sym.Name] = true
+		}
+	}
+	return names
+}
+
+// resolveVar reads the DWARF type of the variable v at offset.
+func (d *DwarfRT) resolveVar(v *globalVar, img *proc.Image, data *dwarf.Data, offset dwarf.Offset) {
+	reader := img.DwarfReader()
+	reader.Seek(offset)
+	entry, err := reader.Next()
+	switch {
+	case err != nil:
+		v.err = fmt.Errorf("read DWARF entry at %#x: %w", offset, err)
+		return
+	case entry == nil || entry.Tag != dwarf.TagVariable:
+		v.err = fmt.Errorf("DWARF entry at %#x is not a variable", offset)
+		return
+	}
+	// delve prefixes C variables with "C."
+	if name, _ := entry.Val(dwarf.AttrName).(string); name != strings.TrimPrefix(v.name, "C.") {
+		v.err = fmt.Errorf("DWARF entry at %#x is the variable %s", offset, name)
+		return
+	}
+	dtyp, err := d.entryType(data, entry)
+	if err != nil {
+		v.err = fmt.Errorf("read DWARF type: %w", err)
+		return
+	}
+	v.dtyp = dtyp
+	v.typeName = dwarfTypeName(dtyp)
+	if v.typeName == "<unspecified>" || v.typeName == "" {
+		v.err = fmt.Errorf("DWARF type %s has no name", dtyp)
+	}
+}
+
+// imageVars calls f with the package level variables of img, read from its DWARF.
+func (d *DwarfRT) imageVars(img *proc.Image, f func(v *globalVar)) {
+	rDwarf := reflect.ValueOf(img).Elem().FieldByName("dwarf")
+	if !rDwarf.IsValid() || rDwarf.IsNil() {
+		return
+	}
+	dwarfData := (*dwarf.Data)(unsafe.Pointer(rDwarf.Pointer()))
+	ptrSize := d.bi.Arch.PtrSize()
 
-			rtyp, err := d.FindType(dname)
-			if err != nil || rtyp == nil {
-				continue
+	reader := img.DwarfReader()
+	isgo := false
+	for {
+		entry, err := reader.Next()
+		if err != nil || entry == nil {
+			break
+		}
+		if entry.Tag == dwarf.TagCompileUnit {
+			lang, _ := entry.Val(dwarf.AttrLanguage).(int64)
+			isgo = lang == 22 // DW_LANG_Go
+			continue
+		}
+		if entry.Children {
+			reader.SkipChildren()
+		}
+		if entry.Tag != dwarf.TagVariable {
+			continue
+		}
+		name, ok := entry.Val(dwarf.AttrName).(string)
+		if !ok {
+			continue
+		}
+		if !isgo {
+			name = "C." + name
+		}
+		v := &globalVar{img: img, name: name}
+		d.resolveVar(v, img, dwarfData, entry.Offset)
+		loc, _ := entry.Val(dwarf.AttrLocation).([]byte)
+		if len(loc) == ptrSize+1 && loc[0] == 0x03 { // DW_OP_addr
+			if addr, err := readUintRaw(bytes.NewReader(loc[1:]), binary.LittleEndian, ptrSize); err == nil {
+				v.addr = addr + img.StaticBase
 			}
-			d.globals[name] = reflect.NewAt(rtyp, unsafe.Pointer(uintptr(rAddr.Uint()))).Elem()
 		}
+		if v.err == nil && v.addr == 0 {
+			v.err = errors.New("no static address")
+		}
+		f(v)
 	}
 }
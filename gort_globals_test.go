@@ -0,0 +1,91 @@
+package gort_test
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/lsg2020/gort"
+)
+
+var rawLimit int32 = 9
+
+// cgoGlobal is a C global of the runtime cgo support whose DWARF int is 4
+// bytes, it does not fit the Go int and is skipped.
+const cgoGlobal = "C.runtime_init_done"
+
+func TestGlobalDiagnostics(t *testing.T) {
+	rt := newRT(t)
+	diags, err := rt.GlobalDiagnostics()
+	if err != nil {
+		t.Fatalf("global diagnostics err %s", err)
+	}
+	if !sort.SliceIsSorted(diags, func(i, j int) bool { return diags[i].Name < diags[j].Name }) {
+		t.Errorf("diagnostics not sorted by name")
+	}
+	var diag *gort.GlobalDiagnostic
+	for _, d := range diags {
+		if d.Err == nil {
+			t.Errorf("diagnostic of %s without an error", d.Name)
+		}
+		if d.Name == testPkg+".rawLimit" {
+			t.Errorf("usable global %s reported", d.Name)
+		}
+		if d.Name == cgoGlobal {
+			diag = d
+		}
+	}
+	if diag == nil {
+		t.Skipf("no %s in the test binary", cgoGlobal)
+	}
+	if diag.Addr == 0 || diag.TypeName != "int" || !strings.Contains(diag.Error(), "DWARF says 4") {
+		t.Errorf("diagnostic %s addr %#x type %s", diag, diag.Addr, diag.TypeName)
+	}
+
+	_, err = rt.FindGlobal(cgoGlobal)
+	var findDiag *gort.GlobalDiagnostic
+	if !errors.As(err, &findDiag) || findDiag.Name != cgoGlobal || !errors.Is(err, gort.ErrNotFound) {
+		t.Errorf("find skipped global err %v", err)
+	}
+
+	raw, err := rt.FindRawGlobal(cgoGlobal)
+	if err != nil {
+		t.Fatalf("find raw global err %s", err)
+	}
+	if raw.Addr != diag.Addr || raw.DwarfType == nil || len(raw.Mem) != 4 {
+		t.Errorf("raw global addr %#x type %v mem %d bytes", raw.Addr, raw.DwarfType, len(raw.Mem))
+	}
+	if !errors.As(raw.Err, &findDiag) {
+		t.Errorf("raw global of a skipped global err %v", raw.Err)
+	}
+}
+
+func TestFindRawGlobal(t *testing.T) {
+	rt := newRT(t)
+	if rawLimit != 9 {
+		t.Fatalf("fixtures changed")
+	}
+	raw, err := rt.FindRawGlobal(testPkg + ".rawLimit")
+	if err != nil {
+		t.Fatalf("find raw global err %s", err)
+	}
+	if raw.Err != nil || raw.TypeName != "int32" || raw.Addr != uint64(uintptr(unsafe.Pointer(&rawLimit))) || len(raw.Mem) != 4 {
+		t.Fatalf("raw global %+v", raw)
+	}
+	mem := (*int32)(unsafe.Pointer(&raw.Mem[0]))
+	if *mem != 9 {
+		t.Errorf("raw global mem %v", raw.Mem)
+	}
+	// writes go to the variable
+	*mem = 11
+	if rawLimit != 11 {
+		t.Errorf("write raw global gave %d", rawLimit)
+	}
+	rawLimit = 9
+
+	if _, err := rt.FindRawGlobal(testPkg + ".missing"); !errors.Is(err, gort.ErrNotFound) {
+		t.Errorf("find missing raw global err %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package gort
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// HookHandle is an installed hook, Remove uninstalls it.
+type HookHandle struct {
+	Func string
+	Addr uint64
+
+	before     func(args []reflect.Value)
+	after      func(results []reflect.Value)
+	original   reflect.Value // runs the relocated prologue, then the rest of the function
+	handler    reflect.Value // *func, keeps the function the entry jumps to alive
+	trampoline *hookTrampoline
+
+	active      int32 // calls running the relocated prologue
+	removed     int32
+	releaseOnce sync.Once
+}
+
+// Hook calls before with the arguments and after with the results of every
+// call to the function name, which is still run through a copy of its first
+// instructions. Either callback may be nil, they run on the calling goroutine
+// and must not modify the values. The jump written over the entry may cover
+// more than the first instruction, goroutines stopped or interrupted by a
+// signal right after it when the hook is installed may crash, as with Patch.
+// Calls the compiler inlined are not hooked, build with -gcflags=all=-l.
+// Only linux/amd64 is supported.
+func (d *DwarfRT) Hook(name string, before func(args []reflect.Value), after func(results []reflect.Value)) (*HookHandle, error) {
+	if err := d.rlock(); err != nil {
+		return nil, err
+	}
+	f, err := d.findFunc(name)
+	if err != nil {
+		d.mu.RUnlock()
+		return nil, err
+	}
+	inTyps, outTyps, _, _, err := d.getFunctionArgTypes(f)
+	d.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	ftyp := reflect.FuncOf(inTyps, outTyps, false)
+
+	patchesMu.Lock()
+	defer patchesMu.Unlock()
+	if _, ok := patches[f.Entry]; ok {
+		return nil, fmt.Errorf("hook %s is already patched", name)
+	}
+	trampoline, err := newHookTrampoline(f.Entry, f.End)
+	if err != nil {
+		return nil, fmt.Errorf("hook %s: %w", name, err)
+	}
+
+	h := &HookHandle{Func: f.Name, Addr: f.Entry, before: before, after: after, trampoline: trampoline}
+	h.original = CreateFuncForCodePtr(ftyp, trampoline.relocated)
+	h.handler = reflect.New(ftyp)
+	h.handler.Elem().Set(reflect.MakeFunc(ftyp, h.call))
+	if err := trampoline.install(f.Entry, *(*uintptr)(unsafe.Pointer(h.handler.Pointer()))); err != nil {
+		trampoline.release()
+		return nil, fmt.Errorf("hook %s: %w", name, err)
+	}
+	patches[f.Entry] = h
+	return h, nil
+}
+
+// Remove restores the entry of the hooked function, calls already in the hook
+// complete normally. The code of the hook is reused once they returned.
+func (h *HookHandle) Remove() error {
+	patchesMu.Lock()
+	defer patchesMu.Unlock()
+	if p, ok := patches[h.Addr].(*HookHandle); !ok || p != h {
+		return fmt.Errorf("hook %s is not installed", h.Func)
+	}
+	if err := h.trampoline.uninstall(h.Addr); err != nil {
+		return err
+	}
+	delete(patches, h.Addr)
+	atomic.StoreInt32(&h.removed, 1)
+	if atomic.LoadInt32(&h.active) == 0 {
+		h.release()
+	}
+	return nil
+}
+
+// release frees the trampoline of a removed hook no call runs anymore.
+func (h *HookHandle) release() {
+	h.releaseOnce.Do(h.trampoline.release)
+}
+
+func (h *HookHandle) call(args []reflect.Value) []reflect.Value {
+	atomic.AddInt32(&h.active, 1)
+	defer func() {
+		if atomic.AddInt32(&h.active, -1) == 0 && atomic.LoadInt32(&h.removed) == 1 {
+			h.release()
+		}
+	}()
+
+	if hookRestarted() {
+		return h.original.Call(args)
+	}
+	if h.before != nil {
+		h.before(args)
+	}
+	results := h.original.Call(args)
+	if h.after != nil {
+		h.after(results)
+	}
+	return results
+}
+
+var (
+	hookCallName     string
+	hookCallNameOnce sync.Once
+)
+
+// hookRestarted reports whether the hook was entered again by the relocated
+// prologue, whose stack check jumps back to the function entry after growing
+// the stack or being preempted. The hook was then called by its own original call.
+func hookRestarted() bool {
+	hookCallNameOnce.Do(func() {
+		hookCallName = runtime.FuncForPC(reflect.ValueOf((*HookHandle).call).Pointer()).Name()
+	})
+
+	// the frames of makeFuncStub and the runtime call wrappers are elided
+	var pcs [8]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs[:])])
+	reflected := false
+	for {
+		frame, more := frames.Next()
+		switch {
+		case isReflectCallFrame(frame.Function):
+			reflected = true
+		case frame.Function == hookCallName:
+			return reflected
+		default:
+			return false
+		}
+		if !more {
+			return false
+		}
+	}
+}
+
+// isReflectCallFrame reports whether function is on the way from
+// reflect.Value.Call to the called function.
+func isReflectCallFrame(function string) bool {
+	switch function {
+	case "reflect.Value.call", "reflect.Value.Call", "runtime.reflectcall":
+		return true
+	}
+	return len(function) > len("runtime.call") && function[:len("runtime.call")] == "runtime.call"
+}
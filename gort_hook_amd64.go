@@ -0,0 +1,235 @@
+//go:build linux && amd64
+
+package gort
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"golang.org/x/arch/x86/x86asm"
+)
+
+const (
+	hookJumpLen  = 5   // JMP rel32 written over the entry
+	hookSlotSize = 128 // stub and relocated prologue of one hook
+)
+
+// hookTrampoline is the code of a hook, allocated within reach of a rel32
+// jump from the function: a stub loading the hook func value and the
+// relocated instructions overwritten by the jump.
+type hookTrampoline struct {
+	stub      uint64
+	relocated uint64
+	saved     uint64 // original first 8 bytes of the entry
+}
+
+// newHookTrampoline relocates the first instructions of the function [entry, end).
+func newHookTrampoline(entry, end uint64) (*hookTrampoline, error) {
+	if entry%8 != 0 {
+		return nil, fmt.Errorf("entry %#x is not aligned", entry)
+	}
+	slot, err := allocHookSlot(entry)
+	if err != nil {
+		return nil, err
+	}
+	t := &hookTrampoline{stub: slot, relocated: slot + 16}
+	code, err := relocatePrologue(entry, end, t.relocated)
+	if err == nil && len(code) > hookSlotSize-16 {
+		err = fmt.Errorf("relocated prologue is %d bytes long", len(code))
+	}
+	if err != nil {
+		t.release()
+		return nil, err
+	}
+	copy(textBytes(t.relocated, len(code)), code)
+	return t, nil
+}
+
+// install points the stub at funcval and the entry at the stub with one atomic store.
+func (t *hookTrampoline) install(entry uint64, funcval uintptr) error {
+	copy(textBytes(t.stub, 12), patchCode(funcval))
+
+	t.saved = atomic.LoadUint64((*uint64)(addrPointer(entry)))
+	var jump [8]byte
+	binary.LittleEndian.PutUint64(jump[:], t.saved)
+	jump[0] = 0xe9
+	binary.LittleEndian.PutUint32(jump[1:], uint32(int32(int64(t.stub)-int64(entry+hookJumpLen))))
+	return storeText(entry, binary.LittleEndian.Uint64(jump[:]))
+}
+
+func (t *hookTrampoline) uninstall(entry uint64) error {
+	return storeText(entry, t.saved)
+}
+
+// release returns the slot of the trampoline for reuse by later hooks.
+func (t *hookTrampoline) release() {
+	hookMem.Lock()
+	hookMem.free = append(hookMem.free, t.stub)
+	hookMem.Unlock()
+}
+
+// storeText atomically stores the aligned code word at addr.
+func storeText(addr uint64, word uint64) error {
+	pageSize := uint64(syscall.Getpagesize())
+	pages := textBytes(addr&^(pageSize-1), int(pageSize))
+	if err := syscall.Mprotect(pages, syscall.PROT_READ|syscall.PROT_WRITE|syscall.PROT_EXEC); err != nil {
+		return err
+	}
+	atomic.StoreUint64((*uint64)(addrPointer(addr)), word)
+	return syscall.Mprotect(pages, syscall.PROT_READ|syscall.PROT_EXEC)
+}
+
+// relocatePrologue copies the instructions covering the first hookJumpLen
+// bytes of the function [entry, end) to run at addr, followed by a jump to
+// the next instruction. Relative branches and RIP relative operands are
+// adjusted, calls can not be relocated as they would return into unknown code.
+func relocatePrologue(entry, end, addr uint64) ([]byte, error) {
+	insts, err := decodeFunc(entry, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var code []byte
+	pc := entry
+	terminal := false
+	for _, inst := range insts {
+		if pc >= entry+hookJumpLen {
+			break
+		}
+		raw := textBytes(pc, inst.Len)
+		next := pc + uint64(inst.Len)
+		at := addr + uint64(len(code))
+		rel, isRel := inst.Args[0].(x86asm.Rel)
+		switch {
+		case inst.Op == x86asm.CALL || inst.Op == x86asm.LCALL:
+			return nil, fmt.Errorf("call at %#x can not be relocated", pc)
+		case isRel && inst.Op == x86asm.JMP:
+			code = appendRel32(code, at, []byte{0xe9}, uint64(int64(next)+int64(rel)))
+			terminal = true
+		case isRel && (raw[0]&0xf0 == 0x70 || raw[0] == 0x0f && raw[1]&0xf0 == 0x80):
+			cc := raw[0] & 0x0f
+			if raw[0] == 0x0f {
+				cc = raw[1] & 0x0f
+			}
+			code = appendRel32(code, at, []byte{0x0f, 0x80 | cc}, uint64(int64(next)+int64(rel)))
+		case isRel:
+			return nil, fmt.Errorf("branch %s at %#x can not be relocated", inst.Op, pc)
+		case inst.PCRel == 4:
+			target := int64(next) + int64(int32(binary.LittleEndian.Uint32(raw[inst.PCRelOff:])))
+			disp := target - int64(at+uint64(inst.Len))
+			if disp != int64(int32(disp)) {
+				return nil, fmt.Errorf("operand at %#x out of reach", pc)
+			}
+			relocated := append([]byte(nil), raw...)
+			binary.LittleEndian.PutUint32(relocated[inst.PCRelOff:], uint32(int32(disp)))
+			code = append(code, relocated...)
+		case inst.PCRel != 0:
+			return nil, fmt.Errorf("instruction %s at %#x can not be relocated", inst.Op, pc)
+		default:
+			code = append(code, raw...)
+			terminal = inst.Op == x86asm.RET || inst.Op == x86asm.UD1 || inst.Op == x86asm.UD2
+		}
+		pc = next
+	}
+	if pc < entry+hookJumpLen && (!terminal || patchSpace(entry, pc, hookJumpLen) < hookJumpLen) {
+		return nil, fmt.Errorf("function is %d bytes long, the jump needs %d", pc-entry, hookJumpLen)
+	}
+
+	// the rest of the function must not branch into the overwritten instructions
+	off := entry
+	for _, inst := range insts {
+		if rel, ok := inst.Args[0].(x86asm.Rel); ok {
+			if target := uint64(int64(off) + int64(inst.Len) + int64(rel)); target > entry && target < pc {
+				return nil, fmt.Errorf("branch at %#x into the relocated prologue", off)
+			}
+		}
+		off += uint64(inst.Len)
+	}
+
+	if !terminal {
+		code = appendRel32(code, addr+uint64(len(code)), []byte{0xe9}, pc)
+	}
+	return code, nil
+}
+
+// appendRel32 appends the instruction op rel32 at addr branching to target.
+func appendRel32(code []byte, addr uint64, op []byte, target uint64) []byte {
+	code = append(code, op...)
+	rel := int64(target) - int64(addr+uint64(len(op))+4)
+	var rel32 [4]byte
+	binary.LittleEndian.PutUint32(rel32[:], uint32(int32(rel)))
+	return append(code, rel32[:]...)
+}
+
+// decodeFunc decodes the instructions of the function [entry, end).
+func decodeFunc(entry, end uint64) ([]x86asm.Inst, error) {
+	var insts []x86asm.Inst
+	for pc := entry; pc < end; {
+		inst, err := x86asm.Decode(textBytes(pc, int(end-pc)), 64)
+		if err != nil {
+			return nil, fmt.Errorf("decode instruction at %#x: %w", pc, err)
+		}
+		insts = append(insts, inst)
+		pc += uint64(inst.Len)
+	}
+	return insts, nil
+}
+
+var hookMem struct {
+	sync.Mutex
+	pages []uint64
+	used  map[uint64]uint64
+	free  []uint64 // slots of removed hooks
+}
+
+// allocHookSlot returns hookSlotSize bytes of executable memory within reach of addr.
+func allocHookSlot(addr uint64) (uint64, error) {
+	const reach = 1<<31 - 1<<20
+	near := func(page uint64) bool {
+		dist := int64(page - addr)
+		return dist < reach && dist > -reach
+	}
+
+	hookMem.Lock()
+	defer hookMem.Unlock()
+	for i, slot := range hookMem.free {
+		if near(slot) {
+			hookMem.free = append(hookMem.free[:i], hookMem.free[i+1:]...)
+			return slot, nil
+		}
+	}
+	pageSize := uint64(syscall.Getpagesize())
+	for _, page := range hookMem.pages {
+		if near(page) && hookMem.used[page]+hookSlotSize <= pageSize {
+			slot := page + hookMem.used[page]
+			hookMem.used[page] += hookSlotSize
+			return slot, nil
+		}
+	}
+
+	const mapFixedNoreplace = 0x100000
+	for i := uint64(1); i < 1024; i++ {
+		for _, hint := range []uint64{addr&^(pageSize-1) - i<<20, addr&^(pageSize-1) + i<<20} {
+			page, _, errno := syscall.Syscall6(syscall.SYS_MMAP, uintptr(hint), uintptr(pageSize),
+				syscall.PROT_READ|syscall.PROT_WRITE|syscall.PROT_EXEC,
+				syscall.MAP_PRIVATE|syscall.MAP_ANONYMOUS|mapFixedNoreplace, ^uintptr(0), 0)
+			if errno != 0 {
+				continue
+			}
+			if !near(uint64(page)) {
+				syscall.Syscall(syscall.SYS_MUNMAP, page, uintptr(pageSize), 0)
+				continue
+			}
+			if hookMem.used == nil {
+				hookMem.used = make(map[uint64]uint64)
+			}
+			hookMem.pages = append(hookMem.pages, uint64(page))
+			hookMem.used[uint64(page)] = hookSlotSize
+			return uint64(page), nil
+		}
+	}
+	return 0, fmt.Errorf("no memory within reach of %#x", addr)
+}
@@ -0,0 +1,132 @@
+package gort_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lsg2020/gort"
+)
+
+//go:noinline
+func hookAdd(a, b int) int {
+	return a + b
+}
+
+type hookServer struct {
+	name string
+}
+
+//go:noinline
+func (s *hookServer) handle(req string, n int) (string, error) {
+	var buf [64]byte
+	copy(buf[:], req)
+	return fmt.Sprintf("%s:%s:%d", s.name, buf[:len(req)], n), nil
+}
+
+//go:noinline
+func hookDeep(n int) int {
+	if n == 0 {
+		return 0
+	}
+	return hookDeep(n-1) + 1
+}
+
+func TestHook(t *testing.T) {
+	rt := newRT(t)
+	var args, results []int64
+	h, err := rt.Hook(testPkg+".hookAdd", func(in []reflect.Value) {
+		args = append(args, in[0].Int(), in[1].Int())
+	}, func(out []reflect.Value) {
+		results = append(results, out[0].Int())
+	})
+	if errors.Is(err, gort.ErrNotSupport) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("hook err %s", err)
+	}
+	if n := hookAdd(3, 4); n != 7 {
+		t.Errorf("hooked hookAdd returned %d", n)
+	}
+	if !reflect.DeepEqual(args, []int64{3, 4}) || !reflect.DeepEqual(results, []int64{7}) {
+		t.Errorf("hook saw args %v results %v", args, results)
+	}
+	if _, err := rt.Hook(testPkg+".hookAdd", nil, nil); err == nil {
+		t.Errorf("second hook succeeded")
+	}
+	if err := h.Remove(); err != nil {
+		t.Fatalf("remove err %s", err)
+	}
+	if n := hookAdd(1, 1); n != 2 || len(results) != 1 {
+		t.Errorf("removed hook returned %d, saw results %v", n, results)
+	}
+	if err := h.Remove(); err == nil {
+		t.Errorf("second remove succeeded")
+	}
+
+	// the code of removed hooks is reused
+	for i := 0; i < 100; i++ {
+		h, err := rt.Hook(testPkg+".hookAdd", nil, func(out []reflect.Value) {})
+		if err != nil {
+			t.Fatalf("hook %d err %s", i, err)
+		}
+		if n := hookAdd(i, 1); n != i+1 {
+			t.Fatalf("hook %d returned %d", i, n)
+		}
+		if err := h.Remove(); err != nil {
+			t.Fatalf("remove %d err %s", i, err)
+		}
+	}
+}
+
+func TestHookConcurrentCalls(t *testing.T) {
+	rt := newRT(t)
+	s := &hookServer{"srv"}
+	var handled, befores, afters int64
+	h1, err := rt.Hook(testPkg+".(*hookServer).handle", func(in []reflect.Value) {
+		atomic.AddInt64(&handled, 1)
+	}, nil)
+	if errors.Is(err, gort.ErrNotSupport) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("hook method err %s", err)
+	}
+	defer h1.Remove()
+	h2, err := rt.Hook(testPkg+".hookDeep", func([]reflect.Value) {
+		atomic.AddInt64(&befores, 1)
+	}, func([]reflect.Value) {
+		atomic.AddInt64(&afters, 1)
+	})
+	if err != nil {
+		t.Fatalf("hook recursive func err %s", err)
+	}
+	defer h2.Remove()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if out, _ := s.handle("x", j); out != fmt.Sprintf("srv:x:%d", j) {
+					t.Errorf("hooked handle returned %q", out)
+				}
+				if n := hookDeep(10); n != 10 {
+					t.Errorf("hooked hookDeep returned %d", n)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if handled != 8*20 {
+		t.Errorf("handle hooked %d times", handled)
+	}
+	if befores != 8*20*11 || afters != befores {
+		t.Errorf("hookDeep hooked %d times before and %d after", befores, afters)
+	}
+}
@@ -0,0 +1,253 @@
+package gort
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"unsafe"
+
+	"github.com/go-delve/delve/pkg/dwarf/godwarf"
+	"github.com/go-delve/delve/pkg/proc"
+)
+
+// AmbiguousError is returned when a name is defined by several images or
+// vendored packages, each candidate is a qualified name selecting one of them.
+// It matches ErrAmbiguous with errors.Is.
+type AmbiguousError struct {
+	Name       string
+	Candidates []string
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("%s is %s: %s", e.Name, ErrAmbiguous, strings.Join(e.Candidates, ", "))
+}
+
+func (e *AmbiguousError) Is(target error) bool {
+	return target == ErrAmbiguous
+}
+
+// FindTypeIn is FindType restricted to the image whose path or file name is image.
+func (d *DwarfRT) FindTypeIn(image, name string) (reflect.Type, error) {
+	if err := d.rlock(); err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	img, err := d.findImage(image)
+	if err != nil {
+		return nil, err
+	}
+	d.dwarfMu.Lock()
+	defer d.dwarfMu.Unlock()
+	typ, err := d.resolveTypeNameIn(img, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUnderlying(name, typ); err != nil {
+		return nil, err
+	}
+	return typ, nil
+}
+
+// FindFuncIn is FindFunc restricted to the image whose path or file name is image.
+func (d *DwarfRT) FindFuncIn(image, name string, variadic bool) (reflect.Value, error) {
+	if err := d.rlock(); err != nil {
+		return reflect.Value{}, err
+	}
+	defer d.mu.RUnlock()
+
+	img, err := d.findImage(image)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	f, err := d.findFuncIn(img, name)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	inTyps, outTyps, _, _, err := d.getFunctionArgTypes(f)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return CreateFuncForCodePtr(reflect.FuncOf(inTyps, outTyps, variadic), f.Entry), nil
+}
+
+// findImage returns the loaded image whose path or file name is name.
+func (d *DwarfRT) findImage(name string) (*proc.Image, error) {
+	var found []*proc.Image
+	for _, img := range d.bi.Images {
+		if img.Path == name {
+			return img, nil
+		}
+		if filepath.Base(img.Path) == name {
+			found = append(found, img)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("image %s %w", name, ErrNotFound)
+	case 1:
+		return found[0], nil
+	}
+	err := &AmbiguousError{Name: name}
+	for _, img := range found {
+		err.Candidates = append(err.Candidates, img.Path)
+	}
+	return nil, err
+}
+
+// splitImage splits an image qualified name such as "libfoo.so:pkg.F",
+// img is nil when name is not qualified by a loaded image.
+func (d *DwarfRT) splitImage(name string) (*proc.Image, string) {
+	colon := strings.IndexByte(name, ':')
+	if colon <= 0 {
+		return nil, name
+	}
+	img, err := d.findImage(name[:colon])
+	if err != nil {
+		return nil, name
+	}
+	return img, name[colon+1:]
+}
+
+// qualifiedName returns name qualified by the file name of img.
+func qualifiedName(img *proc.Image, name string) string {
+	return filepath.Base(img.Path) + ":" + name
+}
+
+// findDwarfType returns the DWARF type name in img, or in any image when img
+// is nil. The main executable wins over the other images, a name defined only
+// by several other images is ambiguous. d.dwarfMu must be held.
+func (d *DwarfRT) findDwarfType(img *proc.Image, name string) (godwarf.Type, error) {
+	if img != nil && img != d.bi.Images[0] {
+		off, ok := d.imageTypes(img)[name]
+		if !ok {
+			return nil, fmt.Errorf("type %s in %s %w", name, img.Path, ErrNotFound)
+		}
+		return img.Type(off)
+	}
+
+	dtyp, err := findType(d.bi, name)
+	if err == nil {
+		if dtyp.Common().Index == 0 {
+			return dtyp, nil
+		}
+		if img != nil {
+			return nil, fmt.Errorf("type %s in %s %w", name, img.Path, ErrNotFound)
+		}
+		if err := d.checkAmbiguousType(name); err != nil {
+			return nil, err
+		}
+		return dtyp, nil
+	}
+	if img == nil {
+		if dtyp, verr := d.findVendoredType(name); verr != ErrNotFound {
+			return dtyp, verr
+		}
+	}
+	return nil, err
+}
+
+// checkAmbiguousType returns an AmbiguousError when more than one image other
+// than the main executable defines the type name.
+func (d *DwarfRT) checkAmbiguousType(name string) error {
+	var candidates []string
+	for _, img := range d.bi.Images[1:] {
+		if _, ok := d.imageTypes(img)[name]; ok {
+			candidates = append(candidates, qualifiedName(img, name))
+		}
+	}
+	if len(candidates) > 1 {
+		sort.Strings(candidates)
+		return &AmbiguousError{Name: name, Candidates: candidates}
+	}
+	return nil
+}
+
+// findVendoredType looks name up in vendored packages, returns ErrNotFound
+// when there is no vendored copy. d.dwarfMu must be held.
+func (d *DwarfRT) findVendoredType(name string) (godwarf.Type, error) {
+	if expr, err := parseTypeExpr(name); err != nil || expr.kind != typeExprNamed || !strings.Contains(name, ".") {
+		return nil, ErrNotFound
+	}
+	types, err := d.bi.Types()
+	if err != nil {
+		return nil, err
+	}
+	var candidates []string
+	for _, typeName := range types {
+		if strings.HasSuffix(typeName, "/vendor/"+name) || strings.HasPrefix(typeName, "vendor/") && typeName[len("vendor/"):] == name {
+			candidates = append(candidates, typeName)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, ErrNotFound
+	case 1:
+		return findType(d.bi, candidates[0])
+	}
+	sort.Strings(candidates)
+	return nil, &AmbiguousError{Name: name, Candidates: candidates}
+}
+
+// imageTypes maps the type names defined by img to their DWARF offsets,
+// delve only keeps the first image defining a name.
+func (d *DwarfRT) imageTypes(img *proc.Image) map[string]dwarf.Offset {
+	snap := d.snap
+	snap.imageTypesMu.Lock()
+	defer snap.imageTypesMu.Unlock()
+
+	if types, ok := snap.imageTypeNames[img]; ok {
+		return types
+	}
+	if snap.imageTypeNames == nil {
+		snap.imageTypeNames = make(map[*proc.Image]map[string]dwarf.Offset)
+	}
+	types := make(map[string]dwarf.Offset)
+	snap.imageTypeNames[img] = types
+
+	reader := img.DwarfReader()
+	isgo := false
+	for {
+		entry, err := reader.Next()
+		if err != nil || entry == nil {
+			break
+		}
+		switch entry.Tag {
+		case dwarf.TagCompileUnit:
+			lang, _ := entry.Val(dwarf.AttrLanguage).(int64)
+			isgo = lang == 22 // DW_LANG_Go
+			continue
+		case dwarf.TagArrayType, dwarf.TagBaseType, dwarf.TagClassType, dwarf.TagStructType, dwarf.TagUnionType,
+			dwarf.TagConstType, dwarf.TagVolatileType, dwarf.TagRestrictType, dwarf.TagEnumerationType,
+			dwarf.TagPointerType, dwarf.TagSubroutineType, dwarf.TagTypedef, dwarf.TagUnspecifiedType:
+			if name, ok := entry.Val(dwarf.AttrName).(string); ok {
+				if !isgo {
+					name = "C." + name
+				}
+				if _, exists := types[name]; !exists {
+					types[name] = entry.Offset
+				}
+			}
+		}
+		if entry.Children {
+			reader.SkipChildren()
+		}
+	}
+	return types
+}
+
+// functionImage returns the image defining f.
+func functionImage(f *proc.Function) *proc.Image {
+	rCU := reflect.ValueOf(f).Elem().FieldByName("cu")
+	if !rCU.IsValid() || rCU.IsNil() {
+		return nil
+	}
+	rImage := rCU.Elem().FieldByName("image")
+	if !rImage.IsValid() || rImage.IsNil() {
+		return nil
+	}
+	return (*proc.Image)(unsafe.Pointer(rImage.Pointer()))
+}
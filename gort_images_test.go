@@ -0,0 +1,85 @@
+package gort_test
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/lsg2020/gort"
+)
+
+func TestImageQualifiedNames(t *testing.T) {
+	// a DwarfRT of its own, the plugins are not added to the one shared by the tests
+	rt, err := gort.NewDwarfRT("")
+	if err != nil {
+		t.Fatalf("load dwarf err %s", err)
+	}
+	for _, name := range []string{"a", "b"} {
+		path, addr := loadPlugin(t, rt, name)
+		if err := rt.AddImage(path, addr); err != nil {
+			t.Fatalf("add image %s err %s", name, err)
+		}
+	}
+
+	// names defined by one image are found unqualified
+	if _, err := rt.FindGlobal(pluginPkg + "a.Name"); err != nil {
+		t.Errorf("find global of plugin a err %s", err)
+	}
+	if v, err := rt.FindGlobal("b.so:" + pluginPkg + "b.Name"); err != nil || v.String() != "b" {
+		t.Errorf("find qualified global of plugin b %v err %v", v, err)
+	}
+
+	// names defined by both plugins are ambiguous unless qualified
+	wantCandidates := func(name string) []string {
+		return []string{"a.so:" + name, "b.so:" + name}
+	}
+	checkAmbiguous := func(name string, err error) {
+		t.Helper()
+		var ambiguous *gort.AmbiguousError
+		if !errors.Is(err, gort.ErrAmbiguous) || !errors.As(err, &ambiguous) {
+			t.Errorf("lookup %s err %v, want ErrAmbiguous", name, err)
+			return
+		}
+		sort.Strings(ambiguous.Candidates)
+		if !reflect.DeepEqual(ambiguous.Candidates, wantCandidates(name)) {
+			t.Errorf("lookup %s candidates %v", name, ambiguous.Candidates)
+		}
+	}
+	_, err = rt.FindType(pluginPkg + "lib.Item")
+	checkAmbiguous(pluginPkg+"lib.Item", err)
+	_, err = rt.FindFunc(pluginPkg+"lib.Count", false)
+	checkAmbiguous(pluginPkg+"lib.Count", err)
+	// the dynamic linker binds both plugins to the variables of the first one
+	if _, err := rt.FindGlobal(pluginPkg + "lib.Items"); err != nil {
+		t.Errorf("find global of both plugins err %s", err)
+	}
+	if _, err := rt.FindGlobal("b.so:" + pluginPkg + "lib.Items"); !errors.Is(err, gort.ErrNotFound) {
+		t.Errorf("unused copy of lib.Items err %v, want ErrNotFound", err)
+	}
+
+	typ, err := rt.FindTypeIn("b.so", pluginPkg+"lib.Item")
+	if err != nil {
+		t.Fatalf("find type in b.so err %s", err)
+	}
+	if typ.Kind() != reflect.Struct || typ.NumField() != 1 {
+		t.Errorf("type in b.so is %s", typ)
+	}
+	if _, err := rt.FindType("a.so:" + pluginPkg + "lib.Item"); err != nil {
+		t.Errorf("find qualified type err %s", err)
+	}
+	count, err := rt.FindFuncIn("a.so", pluginPkg+"lib.Count", false)
+	if err != nil {
+		t.Fatalf("find func in a.so err %s", err)
+	}
+	if n := count.Call(nil)[0].Int(); n != 1 {
+		t.Errorf("lib.Count returned %d", n)
+	}
+	if _, err := rt.FindTypeIn("c.so", pluginPkg+"lib.Item"); !errors.Is(err, gort.ErrNotFound) {
+		t.Errorf("find type in unknown image err %v, want ErrNotFound", err)
+	}
+	// the executable wins over the plugins
+	if _, err := rt.FindType("int"); err != nil {
+		t.Errorf("find type int err %s", err)
+	}
+}
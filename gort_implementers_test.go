@@ -0,0 +1,64 @@
+package gort_test
+
+import (
+	"reflect"
+	"testing"
+)
+
+type shape interface {
+	area() int
+}
+
+type square struct {
+	side int
+}
+
+//go:noinline
+func (s square) area() int {
+	return s.side * s.side
+}
+
+type rect struct {
+	w, h int
+}
+
+//go:noinline
+func (r *rect) area() int {
+	return r.w * r.h
+}
+
+type notShape struct{}
+
+var shapes = []shape{square{2}, &rect{2, 3}}
+
+// unrelated only keeps the type notShape in the binary
+var unrelated interface{} = notShape{}
+
+func TestImplementers(t *testing.T) {
+	rt := newRT(t)
+	if shapes[0].area()+shapes[1].area() != 10 {
+		t.Fatalf("fixtures changed")
+	}
+
+	types, err := rt.Implementers(testPkg + ".shape")
+	if err != nil {
+		t.Fatalf("implementers err %s", err)
+	}
+	found := make(map[reflect.Type]bool)
+	for _, typ := range types {
+		found[typ] = true
+	}
+	if !found[reflect.TypeOf(square{})] {
+		t.Errorf("value receiver implementer square not in %v", types)
+	}
+	if !found[reflect.TypeOf(&rect{})] || found[reflect.TypeOf(rect{})] {
+		t.Errorf("pointer receiver implementer *rect not in %v", types)
+	}
+	if found[reflect.TypeOf(notShape{})] {
+		t.Errorf("notShape in %v", types)
+	}
+
+	if _, err := rt.Implementers(testPkg + ".square"); err == nil {
+		t.Errorf("implementers of a struct succeeded")
+	}
+}
@@ -0,0 +1,213 @@
+package gort
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"runtime"
+	"strconv"
+
+	"github.com/go-delve/delve/pkg/dwarf/godwarf"
+	"github.com/go-delve/delve/pkg/proc"
+)
+
+const indexVersion = 1
+
+// Index is a compact description of the types, functions and globals of a
+// binary. It is written after go build by "gort index" and loaded by
+// NewIndexRT, so lookups do not need to parse DWARF at start up.
+type Index struct {
+	Version int
+	BuildID string
+	Types   []IndexedType
+	Funcs   []IndexedFunc
+	Globals []IndexedGlobal
+}
+
+// IndexedType is a type of the indexed binary.
+type IndexedType struct {
+	Name        string
+	DwarfOffset uint64
+	// RuntimeType is the offset of the runtime type descriptor from the start
+	// of the types section, 0 when the binary has none.
+	RuntimeType uint64
+}
+
+// IndexedFunc is a function of the indexed binary.
+type IndexedFunc struct {
+	Name         string
+	Entry        uint64 // link time address
+	DwarfOffset  uint64
+	HasSignature bool // In and Out were read from DWARF
+	In, Out      []IndexedParam
+}
+
+// IndexedParam is a parameter or result of an indexed function.
+type IndexedParam struct {
+	Name string
+	Type string // DWARF type name
+}
+
+// IndexedGlobal is a package level variable of the indexed binary.
+type IndexedGlobal struct {
+	Name string
+	Addr uint64 // link time address
+	Type string // DWARF type name
+}
+
+// BuildIndex reads the DWARF of the executable at path and indexes it.
+func BuildIndex(path string) (*Index, error) {
+	buildID, err := readBuildID(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// load at the link time entry point, so PIE binaries are indexed unrelocated
+	var entryPoint uint64
+	if f, err := elf.Open(path); err == nil {
+		entryPoint = f.Entry
+		f.Close()
+	}
+	bi := proc.NewBinaryInfo(runtime.GOOS, runtime.GOARCH)
+	if err := bi.LoadBinaryInfo(path, entryPoint, nil); err != nil {
+		return nil, err
+	}
+	// only the DWARF helpers are used, nothing reads the memory of this process
+	d := &DwarfRT{bi: bi, snap: &snapshot{}}
+	index := &Index{Version: indexVersion, BuildID: buildID}
+
+	names, err := bi.Types()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		dtyp, err := findType(bi, name)
+		if err != nil {
+			continue
+		}
+		index.Types = append(index.Types, IndexedType{
+			Name:        name,
+			DwarfOffset: uint64(dtyp.Common().Offset),
+			RuntimeType: runtimeTypeOffset(bi, dtyp),
+		})
+	}
+
+	for i := range bi.Functions {
+		fn := &bi.Functions[i]
+		if fn.Entry == 0 {
+			continue
+		}
+		indexed := IndexedFunc{Name: fn.Name, Entry: fn.Entry}
+		if rOffset := reflect.ValueOf(fn).Elem().FieldByName("offset"); rOffset.IsValid() {
+			indexed.DwarfOffset = rOffset.Uint()
+		}
+		if params, err := d.funcParams(fn); err == nil {
+			indexed.HasSignature = true
+			for _, param := range params {
+				p := IndexedParam{Name: param.name, Type: param.typeName}
+				if param.ret {
+					indexed.Out = append(indexed.Out, p)
+				} else {
+					indexed.In = append(indexed.In, p)
+				}
+			}
+		}
+		index.Funcs = append(index.Funcs, indexed)
+	}
+
+	err = d.packageVars(func(v *globalVar) {
+		if v.err == nil {
+			index.Globals = append(index.Globals, IndexedGlobal{Name: v.name, Addr: v.addr, Type: v.typeName})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// runtimeTypeOffset returns the runtime type offset recorded in the DWARF entry of dtyp, or 0.
+func runtimeTypeOffset(bi *proc.BinaryInfo, dtyp godwarf.Type) uint64 {
+	common := dtyp.Common()
+	if common.Index >= len(bi.Images) {
+		return 0
+	}
+	reader := bi.Images[common.Index].DwarfReader()
+	reader.Seek(common.Offset)
+	entry, err := reader.Next()
+	if err != nil || entry == nil {
+		return 0
+	}
+	off, _ := entry.Val(godwarf.AttrGoRuntimeType).(uint64)
+	return off
+}
+
+// Encode writes the index to w.
+func (index *Index) Encode(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(index)
+}
+
+// ReadIndex reads an index written by Index.Encode.
+func ReadIndex(r io.Reader) (*Index, error) {
+	index := &Index{}
+	if err := gob.NewDecoder(r).Decode(index); err != nil {
+		return nil, err
+	}
+	if index.Version != indexVersion {
+		return nil, fmt.Errorf("index version %d, expected %d", index.Version, indexVersion)
+	}
+	return index, nil
+}
+
+// readBuildID returns the Go build ID of the executable at path.
+func readBuildID(path string) (string, error) {
+	if f, err := elf.Open(path); err == nil {
+		defer f.Close()
+		if section := f.Section(".note.go.buildid"); section != nil {
+			data, err := section.Data()
+			if err != nil {
+				return "", err
+			}
+			// note header: name size, desc size and type, then the name "Go\x00\x00"
+			if len(data) < 16 {
+				return "", fmt.Errorf("malformed build id note in %s", path)
+			}
+			descSize := f.ByteOrder.Uint32(data[4:])
+			if uint64(len(data)) < 16+uint64(descSize) {
+				return "", fmt.Errorf("malformed build id note in %s", path)
+			}
+			return string(data[16 : 16+descSize]), nil
+		}
+	}
+
+	// other formats start their text with "\xff Go build ID: \"...\"\n \xff"
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	data := make([]byte, 32*1024)
+	n, err := io.ReadFull(f, data)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	data = data[:n]
+	prefix := []byte("\xff Go build ID: \"")
+	start := bytes.Index(data, prefix)
+	if start < 0 {
+		return "", fmt.Errorf("build id of %s %w", path, ErrNotFound)
+	}
+	end := bytes.Index(data[start:], []byte("\"\n \xff"))
+	if end < 0 {
+		return "", fmt.Errorf("malformed build id in %s", path)
+	}
+	id, err := strconv.Unquote(string(data[start+len(prefix)-1 : start+end+1]))
+	if err != nil {
+		return "", fmt.Errorf("malformed build id in %s", path)
+	}
+	return id, nil
+}
@@ -0,0 +1,100 @@
+package gort_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/lsg2020/gort"
+)
+
+type indexType struct {
+	a, b int
+}
+
+var indexGlobal = indexType{a: 1, b: 2}
+
+//go:noinline
+func indexAdd(a, b int) int {
+	return a + b
+}
+
+// writeIndex encodes index into a file of the temporary directory of t.
+func writeIndex(t *testing.T, index *gort.Index) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.gortidx")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create index err %s", err)
+	}
+	defer f.Close()
+	if err := index.Encode(f); err != nil {
+		t.Fatalf("encode index err %s", err)
+	}
+	return path
+}
+
+func TestIndexRT(t *testing.T) {
+	if indexAdd(indexGlobal.a, indexGlobal.b) != 3 {
+		t.Fatalf("fixtures changed")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("executable err %s", err)
+	}
+	index, err := gort.BuildIndex(exe)
+	if err != nil {
+		t.Fatalf("build index err %s", err)
+	}
+	rt, err := gort.NewIndexRT(writeIndex(t, index))
+	if err != nil {
+		t.Fatalf("load index err %s", err)
+	}
+	if rt.BuildID() != index.BuildID {
+		t.Errorf("build id %s, index %s", rt.BuildID(), index.BuildID)
+	}
+
+	typ, err := rt.FindType(testPkg + ".indexType")
+	if err != nil {
+		t.Fatalf("find type err %s", err)
+	}
+	if typ != reflect.TypeOf(indexGlobal) {
+		t.Errorf("find type got %s", typ)
+	}
+
+	v, err := rt.FindGlobal(testPkg + ".indexGlobal")
+	if err != nil {
+		t.Fatalf("find global err %s", err)
+	}
+	if v.Addr().Interface() != &indexGlobal {
+		t.Errorf("global at %#x, want %p", v.Addr().Pointer(), &indexGlobal)
+	}
+
+	rets, err := rt.CallFunc(testPkg+".indexAdd", false, []reflect.Value{reflect.ValueOf(3), reflect.ValueOf(4)})
+	if err != nil {
+		t.Fatalf("call err %s", err)
+	}
+	if rets[0].Int() != 7 {
+		t.Errorf("indexAdd returned %d", rets[0].Int())
+	}
+	if _, err := rt.FindFuncPc(testPkg + ".missing"); !errors.Is(err, gort.ErrNotFound) {
+		t.Errorf("find missing func err %v, want ErrNotFound", err)
+	}
+}
+
+func TestIndexRTBuildID(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("executable err %s", err)
+	}
+	index, err := gort.BuildIndex(exe)
+	if err != nil {
+		t.Fatalf("build index err %s", err)
+	}
+	index.BuildID += "-stale"
+	if _, err := gort.NewIndexRT(writeIndex(t, index)); !errors.Is(err, gort.ErrBuildIDMismatch) {
+		t.Errorf("load stale index err %v, want ErrBuildIDMismatch", err)
+	}
+}
@@ -0,0 +1,256 @@
+package gort
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"unsafe"
+)
+
+// IndexRT looks up types, functions and globals of the running executable
+// through an index written by "gort index" instead of DWARF. It only covers
+// the main executable, and types without a runtime type descriptor can not
+// be synthesized without DWARF. IndexRT is safe for concurrent use.
+type IndexRT struct {
+	index *Index
+	// bias is added to link time addresses, it is non zero for PIE binaries
+	bias uint64
+	// types is the address of the types section of the executable
+	types uint64
+
+	typeByName   map[string]*IndexedType
+	funcByName   map[string]*IndexedFunc
+	globalByName map[string]*IndexedGlobal
+}
+
+// NewIndexRT loads the index at path, it must have been built from the
+// running executable, otherwise ErrBuildIDMismatch is returned.
+func NewIndexRT(path string) (*IndexRT, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	index, err := ReadIndex(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	buildID, err := readBuildID(exe)
+	if err != nil {
+		return nil, err
+	}
+	if buildID != index.BuildID {
+		return nil, fmt.Errorf("%w: index %s, executable %s", ErrBuildIDMismatch, index.BuildID, buildID)
+	}
+	return newIndexRT(index)
+}
+
+func newIndexRT(index *Index) (*IndexRT, error) {
+	r := &IndexRT{
+		index:        index,
+		typeByName:   make(map[string]*IndexedType, len(index.Types)),
+		funcByName:   make(map[string]*IndexedFunc, len(index.Funcs)),
+		globalByName: make(map[string]*IndexedGlobal, len(index.Globals)),
+	}
+	for i := range index.Types {
+		r.typeByName[index.Types[i].Name] = &index.Types[i]
+	}
+	for i := range index.Funcs {
+		fn := &index.Funcs[i]
+		r.funcByName[fn.Name] = fn
+		if normalized := normalizeInstantiations(fn.Name); normalized != fn.Name {
+			if _, ok := r.funcByName[normalized]; !ok {
+				r.funcByName[normalized] = fn
+			}
+		}
+	}
+	for i := range index.Globals {
+		r.globalByName[index.Globals[i].Name] = &index.Globals[i]
+	}
+
+	// a function of this package anchors the link time addresses,
+	// the runtime type of int anchors the types section
+	anchorPC := reflect.ValueOf(indexAnchor).Pointer()
+	anchor := runtime.FuncForPC(anchorPC)
+	if anchor == nil {
+		return nil, fmt.Errorf("index anchor %w", ErrNotFound)
+	}
+	fn, ok := r.funcByName[anchor.Name()]
+	if !ok {
+		return nil, fmt.Errorf("index anchor %s %w", anchor.Name(), ErrNotFound)
+	}
+	r.bias = uint64(anchorPC) - fn.Entry
+
+	intType, ok := r.typeByName["int"]
+	if !ok || intType.RuntimeType == 0 {
+		return nil, fmt.Errorf("index runtime type int %w", ErrNotFound)
+	}
+	var i interface{} = 0
+	r.types = uint64((*[2]uintptr)(unsafe.Pointer(&i))[0]) - intType.RuntimeType
+	return r, nil
+}
+
+func indexAnchor() {}
+
+// BuildID returns the build ID of the indexed executable.
+func (r *IndexRT) BuildID() string {
+	return r.index.BuildID
+}
+
+func (r *IndexRT) ForeachType(f func(name string)) error {
+	for _, t := range r.index.Types {
+		f(t.Name)
+	}
+	return nil
+}
+
+func (r *IndexRT) FindType(name string) (reflect.Type, error) {
+	return r.lookupType(name)
+}
+
+func (r *IndexRT) lookupType(name string) (reflect.Type, error) {
+	t, ok := r.typeByName[name]
+	if !ok {
+		t, ok = r.typeByName[normalizeTypeName(name)]
+	}
+	if ok && t.RuntimeType != 0 {
+		return runtimeTypeAt(r.types + t.RuntimeType), nil
+	}
+	if builtin, ok := builtinTypes[name]; ok {
+		return builtin, nil
+	}
+
+	expr, err := parseTypeExpr(name)
+	if err != nil || expr.kind == typeExprNamed {
+		if ok {
+			return nil, fmt.Errorf("runtime type of %s %w", name, ErrNotFound)
+		}
+		return nil, fmt.Errorf("type %s %w", name, ErrNotFound)
+	}
+	return buildTypeExpr(expr, r.lookupType)
+}
+
+func (r *IndexRT) ForeachFunc(f func(name string, pc uint64)) error {
+	for _, fn := range r.index.Funcs {
+		f(fn.Name, fn.Entry+r.bias)
+	}
+	return nil
+}
+
+func (r *IndexRT) FindFuncPc(name string) (uint64, error) {
+	fn, err := r.findFunc(name)
+	if err != nil {
+		return 0, err
+	}
+	return fn.Entry + r.bias, nil
+}
+
+func (r *IndexRT) FindFuncType(name string, variadic bool) (reflect.Type, error) {
+	fn, err := r.findFunc(name)
+	if err != nil {
+		return nil, err
+	}
+	inTyps, outTyps, _, err := r.funcArgTypes(fn)
+	if err != nil {
+		return nil, err
+	}
+	return reflect.FuncOf(inTyps, outTyps, variadic), nil
+}
+
+func (r *IndexRT) FindFunc(name string, variadic bool) (reflect.Value, error) {
+	fn, err := r.findFunc(name)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	inTyps, outTyps, _, err := r.funcArgTypes(fn)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return CreateFuncForCodePtr(reflect.FuncOf(inTyps, outTyps, variadic), fn.Entry+r.bias), nil
+}
+
+func (r *IndexRT) CallFunc(name string, variadic bool, args []reflect.Value) ([]reflect.Value, error) {
+	fn, err := r.findFunc(name)
+	if err != nil {
+		return nil, err
+	}
+	inTyps, outTyps, inNames, err := r.funcArgTypes(fn)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCallArgs(name, inTyps, inNames, variadic, args); err != nil {
+		return nil, err
+	}
+	newFunc := CreateFuncForCodePtr(reflect.FuncOf(inTyps, outTyps, variadic), fn.Entry+r.bias)
+	return callRecover(name, newFunc, args)
+}
+
+func (r *IndexRT) findFunc(name string) (*IndexedFunc, error) {
+	fn, ok := r.funcByName[name]
+	if !ok && strings.Contains(name, "[") {
+		fn, ok = r.funcByName[normalizeInstantiations(name)]
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return fn, nil
+}
+
+// funcArgTypes resolves the parameter and result types of fn, inNames are the parameter names.
+func (r *IndexRT) funcArgTypes(fn *IndexedFunc) ([]reflect.Type, []reflect.Type, []string, error) {
+	if !fn.HasSignature {
+		return nil, nil, nil, fmt.Errorf("signature of %s %w", fn.Name, ErrNotFound)
+	}
+	var inTyps, outTyps []reflect.Type
+	var inNames []string
+	for _, param := range fn.In {
+		typ, err := r.lookupType(param.Type)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("get function arg types type err %s:%s", fn.Name, err.Error())
+		}
+		inTyps = append(inTyps, typ)
+		inNames = append(inNames, param.Name)
+	}
+	for _, param := range fn.Out {
+		typ, err := r.lookupType(param.Type)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("get function arg types type err %s:%s", fn.Name, err.Error())
+		}
+		outTyps = append(outTyps, typ)
+	}
+	return inTyps, outTyps, inNames, nil
+}
+
+// ForeachGlobal calls f for the globals whose type has a runtime type descriptor.
+func (r *IndexRT) ForeachGlobal(f func(name string, v reflect.Value)) error {
+	for i := range r.index.Globals {
+		if v, err := r.globalValue(&r.index.Globals[i]); err == nil {
+			f(r.index.Globals[i].Name, v)
+		}
+	}
+	return nil
+}
+
+func (r *IndexRT) FindGlobal(name string) (reflect.Value, error) {
+	global, ok := r.globalByName[name]
+	if !ok {
+		return reflect.Value{}, ErrNotFound
+	}
+	return r.globalValue(global)
+}
+
+func (r *IndexRT) globalValue(global *IndexedGlobal) (reflect.Value, error) {
+	typ, err := r.lookupType(global.Type)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.NewAt(typ, addrPointer(global.Addr+r.bias)).Elem(), nil
+}
@@ -0,0 +1,201 @@
+package gort
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-delve/delve/pkg/proc"
+)
+
+// InlineSite is a call site a function was inlined into.
+type InlineSite struct {
+	Caller string // function containing the inlined code
+	File   string
+	Line   int
+	PC     uint64 // first instruction of the inlined code
+}
+
+// InlinedError is returned for functions without an entry point because the
+// compiler inlined every call to them, build with -gcflags=all=-l to keep them.
+// It matches ErrInlined and ErrNotFound with errors.Is.
+type InlinedError struct {
+	Func  string
+	Sites []InlineSite
+}
+
+func (e *InlinedError) Error() string {
+	sites := make([]string, 0, len(e.Sites))
+	for _, site := range e.Sites {
+		sites = append(sites, fmt.Sprintf("%s:%d", site.File, site.Line))
+	}
+	return fmt.Sprintf("function %s %s at %s", e.Func, ErrInlined, strings.Join(sites, ", "))
+}
+
+func (e *InlinedError) Is(target error) bool {
+	return target == ErrInlined || target == ErrNotFound
+}
+
+// inlinedError returns an InlinedError when name, as matched by match, was
+// only compiled inlined into its callers.
+func (d *DwarfRT) inlinedError(img *proc.Image, name string, match func(string) bool) error {
+	var fn *proc.Function
+	for i := range d.bi.Functions {
+		f := &d.bi.Functions[i]
+		if f.Entry != 0 || len(f.InlinedCalls) == 0 || !match(f.Name) {
+			continue
+		}
+		if img != nil && functionImage(f) != img {
+			continue
+		}
+		fn = f
+		break
+	}
+	if fn == nil {
+		return nil
+	}
+
+	err := &InlinedError{Func: fn.Name}
+	origin := dwarf.Offset(reflect.ValueOf(fn).Elem().FieldByName("offset").Uint())
+	scanned := make(map[*dwarf.Entry]bool)
+	for i := range fn.InlinedCalls {
+		image, dwarfData, cuEntry := compileUnitOf(reflect.ValueOf(&fn.InlinedCalls[i]).Elem().FieldByName("cu"))
+		if image == nil || scanned[cuEntry] {
+			continue
+		}
+		scanned[cuEntry] = true
+		err.Sites = append(err.Sites, d.inlineSites(image, dwarfData, cuEntry, origin)...)
+	}
+	sort.Slice(err.Sites, func(i, j int) bool {
+		a, b := err.Sites[i], err.Sites[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.PC < b.PC
+	})
+	return err
+}
+
+// inlineSites returns the call sites of the inlined subroutines of the compile
+// unit cu whose abstract origin is origin.
+func (d *DwarfRT) inlineSites(image *proc.Image, data *dwarf.Data, cu *dwarf.Entry, origin dwarf.Offset) []InlineSite {
+	var sites []InlineSite
+	files := d.cuFiles(data, cu)
+	reader := image.DwarfReader()
+	reader.Seek(cu.Offset)
+	if _, err := reader.Next(); err != nil {
+		return nil
+	}
+	for {
+		entry, err := reader.Next()
+		if err != nil || entry == nil || entry.Tag == dwarf.TagCompileUnit {
+			break
+		}
+		if entry.Tag != dwarf.TagInlinedSubroutine {
+			continue
+		}
+		if off, _ := entry.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset); off != origin {
+			continue
+		}
+		site := InlineSite{}
+		if line, ok := entry.Val(dwarf.AttrCallLine).(int64); ok {
+			site.Line = int(line)
+		}
+		if fileIndex, ok := entry.Val(dwarf.AttrCallFile).(int64); ok && fileIndex >= 0 && int(fileIndex) < len(files) && files[fileIndex] != nil {
+			site.File = files[fileIndex].Name
+		}
+		if ranges, err := data.Ranges(entry); err == nil && len(ranges) > 0 {
+			site.PC = ranges[0][0] + image.StaticBase
+			if caller := d.bi.PCToFunc(site.PC); caller != nil {
+				site.Caller = caller.Name
+			}
+		}
+		sites = append(sites, site)
+	}
+	return sites
+}
+
+// BuildFlags describes how the Go code of the executable was compiled.
+type BuildFlags struct {
+	Producer  string   // DW_AT_producer of the main package, e.g. "Go cmd/compile go1.21.0; -l regabi"
+	GoVersion string   // e.g. "go1.21.0"
+	Flags     []string // compiler flags recorded for the main package
+	// Inlining and Optimizations report whether any package was compiled
+	// without -l and -N respectively.
+	Inlining      bool
+	Optimizations bool
+}
+
+// BuildFlags reads the compiler flags recorded in DW_AT_producer of the Go
+// compile units of the executable.
+func (d *DwarfRT) BuildFlags() (*BuildFlags, error) {
+	if err := d.rlock(); err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	flags := &BuildFlags{}
+	reader := d.bi.Images[0].DwarfReader()
+	for {
+		entry, err := reader.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+		if entry.Tag != dwarf.TagCompileUnit {
+			if entry.Children {
+				reader.SkipChildren()
+			}
+			continue
+		}
+		reader.SkipChildren()
+		if lang, _ := entry.Val(dwarf.AttrLanguage).(int64); lang != 22 { // DW_LANG_Go
+			continue
+		}
+		producer, _ := entry.Val(dwarf.AttrProducer).(string)
+		version, cuFlags := parseProducer(producer)
+		if !hasFlag(cuFlags, "-l") {
+			flags.Inlining = true
+		}
+		if !hasFlag(cuFlags, "-N") {
+			flags.Optimizations = true
+		}
+		if name, _ := entry.Val(dwarf.AttrName).(string); name == "main" || flags.Producer == "" {
+			flags.Producer, flags.GoVersion, flags.Flags = producer, version, cuFlags
+		}
+	}
+	if flags.Producer == "" {
+		return nil, fmt.Errorf("go compile unit producer %w", ErrNotFound)
+	}
+	return flags, nil
+}
+
+// parseProducer splits "Go cmd/compile go1.21.0; -N -l" into the go version and the flags.
+func parseProducer(producer string) (string, []string) {
+	desc, rest := producer, ""
+	if semicolon := strings.IndexByte(producer, ';'); semicolon >= 0 {
+		desc, rest = producer[:semicolon], producer[semicolon+1:]
+	}
+	fields := strings.Fields(desc)
+	version := ""
+	if len(fields) > 0 {
+		version = fields[len(fields)-1]
+	}
+	return version, strings.Fields(rest)
+}
+
+func hasFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
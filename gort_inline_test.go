@@ -0,0 +1,58 @@
+package gort_test
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/lsg2020/gort"
+)
+
+// inlinedAdd is inlined into its only caller, the tests are built with inlining
+func inlinedAdd(a, b int) int {
+	if a < 0 {
+		panic("negative")
+	}
+	return a + b
+}
+
+var inlinedArg = 1
+
+func TestInlinedError(t *testing.T) {
+	rt := newRT(t)
+	if inlinedAdd(inlinedArg, 2) != 3 {
+		t.Fatalf("fixtures changed")
+	}
+
+	_, err := rt.FindFunc(testPkg+".inlinedAdd", false)
+	var inlined *gort.InlinedError
+	if !errors.As(err, &inlined) || !errors.Is(err, gort.ErrInlined) || !errors.Is(err, gort.ErrNotFound) {
+		t.Fatalf("find inlined func err %v, want an InlinedError", err)
+	}
+	if inlined.Func != testPkg+".inlinedAdd" || len(inlined.Sites) == 0 {
+		t.Fatalf("inlined error %+v", inlined)
+	}
+	site := inlined.Sites[0]
+	if site.Caller != testPkg+".TestInlinedError" || filepath.Base(site.File) != "gort_inline_test.go" || site.Line == 0 || site.PC == 0 {
+		t.Errorf("inline site %+v", site)
+	}
+	if _, err := rt.FindFunc(testPkg+".missing", false); errors.Is(err, gort.ErrInlined) {
+		t.Errorf("missing func err %v", err)
+	}
+}
+
+func TestBuildFlags(t *testing.T) {
+	rt := newRT(t)
+	flags, err := rt.BuildFlags()
+	if err != nil {
+		t.Fatalf("build flags err %s", err)
+	}
+	if !flags.Inlining || !flags.Optimizations {
+		t.Errorf("build flags %+v, the tests are built with inlining and optimizations", flags)
+	}
+	if !strings.HasPrefix(runtime.Version(), flags.GoVersion) || !strings.Contains(flags.Producer, flags.GoVersion) {
+		t.Errorf("go version %s of %q, running %s", flags.GoVersion, flags.Producer, runtime.Version())
+	}
+}
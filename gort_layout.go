@@ -0,0 +1,189 @@
+package gort
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-delve/delve/pkg/dwarf/godwarf"
+)
+
+// FieldLayout describes a struct field as laid out in memory.
+type FieldLayout struct {
+	Name     string
+	Offset   int64
+	Size     int64
+	Align    int64
+	TypeName string // DWARF type name, e.g. "*main.testStruct"
+	Embedded bool
+}
+
+// Layout returns the fields of the struct type typeName from DWARF, in memory order.
+func (d *DwarfRT) Layout(typeName string) ([]FieldLayout, error) {
+	if err := d.rlock(); err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	st, err := d.structType(typeName)
+	if err != nil {
+		return nil, err
+	}
+
+	d.dwarfMu.Lock()
+	defer d.dwarfMu.Unlock()
+
+	fields := make([]FieldLayout, 0, len(st.Field))
+	for _, field := range st.Field {
+		fields = append(fields, FieldLayout{
+			Name:     field.Name,
+			Offset:   field.ByteOffset,
+			Size:     field.Type.Size(),
+			Align:    d.dwarfAlign(field.Type),
+			TypeName: field.Type.Common().Name,
+			Embedded: field.Embedded,
+		})
+	}
+	return fields, nil
+}
+
+// dwarfAlign returns the alignment of dtyp, DWARF does not record it for Go
+// types so it is taken from the runtime type or derived from the layout.
+// d.dwarfMu must be held.
+func (d *DwarfRT) dwarfAlign(dtyp godwarf.Type) int64 {
+	if name := dtyp.Common().Name; name != "" {
+		if typ, err := d.resolveDwarfType(dtyp, name); err == nil {
+			return int64(typ.Align())
+		}
+	}
+	switch dtyp := dtyp.(type) {
+	case *godwarf.TypedefType:
+		return d.dwarfAlign(dtyp.Type)
+	case *godwarf.ArrayType:
+		return d.dwarfAlign(dtyp.Type)
+	case *godwarf.StructType:
+		align := int64(1)
+		for _, field := range dtyp.Field {
+			if a := d.dwarfAlign(field.Type); a > align {
+				align = a
+			}
+		}
+		return align
+	case *godwarf.ComplexType:
+		return dtyp.Size() / 2
+	}
+	align := dtyp.Size()
+	if ptrSize := int64(d.bi.Arch.PtrSize()); align > ptrSize || align <= 0 {
+		align = ptrSize
+	}
+	return align
+}
+
+// FieldByPath returns the settable value at path in v, even when the path goes
+// through unexported fields. Path elements are field names separated by '.',
+// optionally followed by array or slice indexes, e.g. "items[2].name".
+// Pointers on the way are followed, v must be addressable or a pointer.
+func FieldByPath(v reflect.Value, path string) (reflect.Value, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}, fmt.Errorf("field %s of nil pointer", path)
+		}
+		v = v.Elem()
+	}
+	if !v.CanAddr() {
+		return reflect.Value{}, fmt.Errorf("field %s of unaddressable %s", path, v.Type())
+	}
+	v = writable(v)
+	if path == "" {
+		return v, nil
+	}
+
+	walked := ""
+	for _, elem := range strings.Split(path, ".") {
+		name := elem
+		var indexes []string
+		if open := strings.IndexByte(elem, '['); open >= 0 {
+			name = elem[:open]
+			for rest := elem[open:]; rest != ""; {
+				end := strings.IndexByte(rest, ']')
+				if rest[0] != '[' || end < 0 {
+					return reflect.Value{}, fmt.Errorf("invalid field path %s", path)
+				}
+				indexes = append(indexes, rest[1:end])
+				rest = rest[end+1:]
+			}
+		}
+
+		if name != "" {
+			v = derefField(v)
+			if v.Kind() == reflect.Ptr {
+				return reflect.Value{}, fmt.Errorf("field %s: %s is nil", path, strings.TrimSuffix(walked, "."))
+			}
+			if v.Kind() != reflect.Struct {
+				return reflect.Value{}, fmt.Errorf("field %s: %s%s is not a struct", path, walked, v.Type())
+			}
+			field, err := fieldByName(v, name)
+			if errors.Is(err, ErrNotFound) {
+				return reflect.Value{}, fmt.Errorf("field %s: %s%s %w", path, walked, name, err)
+			}
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %s: %s%s: %w", path, walked, name, err)
+			}
+			v = writable(field)
+			walked += name
+		}
+
+		for _, index := range indexes {
+			v = derefField(v)
+			i, err := strconv.Atoi(index)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("field %s: invalid index %s", path, index)
+			}
+			if v.Kind() != reflect.Array && v.Kind() != reflect.Slice {
+				return reflect.Value{}, fmt.Errorf("field %s: %s is not an array or slice", path, walked)
+			}
+			if i < 0 || i >= v.Len() {
+				return reflect.Value{}, fmt.Errorf("field %s: index %d out of range [0:%d]", path, i, v.Len())
+			}
+			v = writable(v.Index(i))
+			walked += "[" + index + "]"
+		}
+		walked += "."
+	}
+	return v, nil
+}
+
+// fieldByName returns the field name of the struct v, also by the DWARF name
+// of a synthesized field. Promoted fields behind a nil embedded pointer fail.
+func fieldByName(v reflect.Value, name string) (reflect.Value, error) {
+	sf, ok := v.Type().FieldByName(name)
+	if !ok {
+		// synthesized types rename unexported fields
+		for i := 0; i < v.NumField(); i++ {
+			if fieldName(v.Type().Field(i)) == name {
+				return v.Field(i), nil
+			}
+		}
+		return reflect.Value{}, ErrNotFound
+	}
+	return v.FieldByIndexErr(sf.Index)
+}
+
+// derefField follows non nil pointers.
+func derefField(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = writable(v.Elem())
+	}
+	return v
+}
+
+// Settable returns v, which must be addressable, with the read only flag of
+// values reached through unexported fields cleared.
+func Settable(v reflect.Value) (reflect.Value, error) {
+	if !v.CanAddr() {
+		return reflect.Value{}, fmt.Errorf("unaddressable value of type %s", v.Type())
+	}
+	return writable(v), nil
+}
@@ -0,0 +1,94 @@
+package gort_test
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"github.com/lsg2020/gort"
+)
+
+type layoutItem struct {
+	name string
+}
+
+type layoutStruct struct {
+	layoutItem
+	flag  bool
+	count int64
+	items []layoutItem
+	next  *layoutStruct
+}
+
+var layoutGlobal = layoutStruct{
+	layoutItem: layoutItem{name: "global"},
+	count:      3,
+	items:      []layoutItem{{"a"}, {"b"}},
+}
+
+func TestLayout(t *testing.T) {
+	rt := newRT(t)
+	fields, err := rt.Layout(testPkg + ".layoutStruct")
+	if err != nil {
+		t.Fatalf("layout err %s", err)
+	}
+	typ := reflect.TypeOf(layoutGlobal)
+	if len(fields) != typ.NumField() {
+		t.Fatalf("layout has %d fields, want %d", len(fields), typ.NumField())
+	}
+	for i, field := range fields {
+		want := typ.Field(i)
+		if field.Name != want.Name || field.Offset != int64(want.Offset) || field.Size != int64(want.Type.Size()) ||
+			field.Align != int64(want.Type.Align()) || field.Embedded != want.Anonymous {
+			t.Errorf("field %+v, want %s at %d size %d align %d", field, want.Name, want.Offset, want.Type.Size(), want.Type.Align())
+		}
+	}
+	if fields[4].TypeName != "*"+testPkg+".layoutStruct" {
+		t.Errorf("field next has type %s", fields[4].TypeName)
+	}
+	if _, err := rt.Layout("int"); err == nil {
+		t.Errorf("layout of int succeeded")
+	}
+}
+
+func TestFieldByPath(t *testing.T) {
+	v := layoutStruct{layoutItem: layoutItem{name: "v"}, items: []layoutItem{{"a"}, {"b"}}}
+	v.next = &layoutStruct{count: 1}
+
+	name, err := gort.FieldByPath(reflect.ValueOf(&v), "items[1].name")
+	if err != nil {
+		t.Fatalf("field by path err %s", err)
+	}
+	name.SetString("patched")
+	if v.items[1].name != "patched" {
+		t.Errorf("items[1].name is %q", v.items[1].name)
+	}
+
+	count, err := gort.FieldByPath(reflect.ValueOf(&v), "next.count")
+	if err != nil {
+		t.Fatalf("field through pointer err %s", err)
+	}
+	count.SetInt(9)
+	if v.next.count != 9 {
+		t.Errorf("next.count is %d", v.next.count)
+	}
+
+	if _, err := gort.FieldByPath(reflect.ValueOf(&v), "items[5].name"); err == nil {
+		t.Errorf("index out of range succeeded")
+	}
+	if _, err := gort.FieldByPath(reflect.ValueOf(&v), "missing"); err == nil {
+		t.Errorf("missing field succeeded")
+	}
+	if _, err := gort.FieldByPath(reflect.ValueOf(v), "count"); err == nil {
+		t.Errorf("field of unaddressable value succeeded")
+	}
+
+	flag, err := gort.Settable(reflect.ValueOf(&v).Elem().Field(1))
+	if err != nil {
+		t.Fatalf("settable err %s", err)
+	}
+	flag.SetBool(true)
+	if !v.flag || unsafe.Pointer(flag.UnsafeAddr()) != unsafe.Pointer(&v.flag) {
+		t.Errorf("settable field not set")
+	}
+}
@@ -0,0 +1,379 @@
+package gort
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unsafe"
+
+	"github.com/go-delve/delve/pkg/dwarf/godwarf"
+)
+
+// Method describes a method of a named type found in DWARF.
+type Method struct {
+	Name     string       // method name, e.g. "print"
+	Func     string       // function symbol, e.g. "main.(*testStruct).print"
+	Recv     string       // type declaring the method, differs from the queried type for promoted methods
+	PtrRecv  bool         // only in the method set of the pointer type
+	Exported bool         // the method name is exported
+	Type     reflect.Type // signature without the receiver, not variadic when the runtime method type is not in the binary
+	Embedded []string     // embedded fields the method is promoted through, empty for declared methods
+
+	path          []embedStep
+	autogenerated bool
+}
+
+// embedStep is one embedded field on the way to a promoted method.
+type embedStep struct {
+	field  string
+	offset int64
+	ptr    bool
+}
+
+// Methods lists the value and pointer receiver methods of the named type,
+// including unexported methods and methods promoted from embedded structs.
+func (d *DwarfRT) Methods(typeName string) ([]Method, error) {
+	if err := d.rlock(); err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	methods, err := d.methodSet(typeName)
+	if err != nil {
+		return nil, err
+	}
+	for i := range methods {
+		methods[i].path = nil
+		methods[i].autogenerated = false
+	}
+	return methods, nil
+}
+
+// MethodByName returns the method name of recv as a func value bound to recv.
+// recv may be a value or a pointer, pointer receiver methods need a pointer
+// or an addressable value. The func is variadic as the runtime method type,
+// unexported methods have none and take their variadic parameter as a slice.
+func (d *DwarfRT) MethodByName(recv reflect.Value, name string) (reflect.Value, error) {
+	if !recv.IsValid() {
+		return reflect.Value{}, fmt.Errorf("method %s on invalid receiver", name)
+	}
+	isPtr := recv.Kind() == reflect.Ptr
+	base := recv.Type()
+	if isPtr {
+		base = base.Elem()
+	}
+	typeName := fullTypeName(base)
+	if synthesized, ok := d.synthesized.Load(base); ok && typeName == "" {
+		typeName = synthesized.(string)
+	}
+	if typeName == "" {
+		return reflect.Value{}, fmt.Errorf("method %s on unnamed type %s", name, recv.Type())
+	}
+
+	if err := d.rlock(); err != nil {
+		return reflect.Value{}, err
+	}
+	methods, err := d.methodSet(typeName)
+	if err != nil {
+		d.mu.RUnlock()
+		return reflect.Value{}, err
+	}
+	var method *Method
+	for i := range methods {
+		if methods[i].Name == name {
+			method = &methods[i]
+			break
+		}
+	}
+	if method == nil {
+		d.mu.RUnlock()
+		return reflect.Value{}, fmt.Errorf("method %s.%s %w", typeName, name, ErrNotFound)
+	}
+	f, err := d.findFunc(method.Func)
+	if err != nil {
+		d.mu.RUnlock()
+		return reflect.Value{}, err
+	}
+	inTyps, outTyps, _, _, err := d.getFunctionArgTypes(f)
+	if err != nil {
+		d.mu.RUnlock()
+		return reflect.Value{}, err
+	}
+	variadic, known := d.funcVariadic(f, inTyps)
+	d.mu.RUnlock()
+	if !known {
+		variadic, _ = runtimeMethodVariadic(recv.Type(), name)
+	}
+	if len(inTyps) == 0 {
+		return reflect.Value{}, fmt.Errorf("method %s has no receiver parameter", method.Func)
+	}
+
+	v := recv
+	if isPtr {
+		if recv.IsNil() {
+			return reflect.Value{}, fmt.Errorf("method %s.%s on nil receiver", typeName, name)
+		}
+		v = recv.Elem()
+	} else if !recv.CanAddr() {
+		if method.PtrRecv {
+			return reflect.Value{}, fmt.Errorf("method %s.%s needs an addressable receiver", typeName, name)
+		}
+		tmp := reflect.New(base).Elem()
+		tmp.Set(recv)
+		v = tmp
+	}
+	v = writable(v)
+
+	for _, step := range method.path {
+		field, ok := fieldAtOffset(v, step.field, step.offset)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("method %s.%s embedded field %s not found", typeName, name, step.field)
+		}
+		v = field
+		if step.ptr {
+			if v.IsNil() {
+				return reflect.Value{}, fmt.Errorf("method %s.%s through nil embedded field %s", typeName, name, step.field)
+			}
+			v = writable(v.Elem())
+		}
+	}
+
+	recvTyp := inTyps[0]
+	var recvArg reflect.Value
+	if recvTyp.Kind() == reflect.Ptr {
+		recvArg = reflect.NewAt(recvTyp.Elem(), unsafe.Pointer(v.UnsafeAddr()))
+	} else {
+		recvArg = reflect.NewAt(recvTyp, unsafe.Pointer(v.UnsafeAddr())).Elem()
+	}
+
+	fn := CreateFuncForCodePtr(reflect.FuncOf(inTyps, outTyps, variadic), f.Entry)
+	bound := reflect.MakeFunc(reflect.FuncOf(inTyps[1:], outTyps, variadic), func(args []reflect.Value) []reflect.Value {
+		// the variadic arguments arrive as a slice
+		if variadic {
+			return fn.CallSlice(append([]reflect.Value{recvArg}, args...))
+		}
+		return fn.Call(append([]reflect.Value{recvArg}, args...))
+	})
+	return bound, nil
+}
+
+// methodSet collects the methods of typeName and the methods promoted from its
+// embedded fields, shallower methods hide deeper ones as in the Go spec.
+func (d *DwarfRT) methodSet(typeName string) ([]Method, error) {
+	type level struct {
+		typeName string
+		path     []embedStep
+	}
+
+	byName := make(map[string]Method)
+	// compiler generated wrappers, such as promoted or instantiated methods,
+	// are only used when the embedded fields do not explain the method
+	wrappers := make(map[string]Method)
+	visited := make(map[string]bool)
+	current := []level{{typeName: typeName}}
+	found := false
+	for depth := 0; len(current) > 0; depth++ {
+		atDepth := make(map[string][]Method)
+		var next []level
+		for _, l := range current {
+			if visited[l.typeName] {
+				continue
+			}
+			visited[l.typeName] = true
+
+			declared := d.declaredMethods(l.typeName)
+			if depth == 0 && len(declared) > 0 {
+				found = true
+			}
+			for _, m := range declared {
+				if _, hidden := byName[m.Name]; hidden {
+					continue
+				}
+				if m.autogenerated {
+					if depth == 0 {
+						wrappers[m.Name] = m
+					}
+					continue
+				}
+				m.path = l.path
+				for _, step := range l.path {
+					m.Embedded = append(m.Embedded, step.field)
+					if step.ptr {
+						m.PtrRecv = false
+					}
+				}
+				atDepth[m.Name] = append(atDepth[m.Name], m)
+			}
+
+			st, err := d.structType(l.typeName)
+			if err != nil {
+				continue
+			}
+			if depth == 0 {
+				found = true
+			}
+			for _, field := range st.Field {
+				if !field.Embedded {
+					continue
+				}
+				ftyp := field.Type
+				step := embedStep{field: field.Name, offset: field.ByteOffset}
+				if ptr, ok := ftyp.(*godwarf.PtrType); ok {
+					ftyp = ptr.Type
+					step.ptr = true
+				}
+				path := append(append([]embedStep(nil), l.path...), step)
+				next = append(next, level{typeName: ftyp.Common().Name, path: path})
+			}
+		}
+		for name, ms := range atDepth {
+			if len(ms) == 1 {
+				byName[name] = ms[0]
+			} else {
+				// ambiguous selector at this depth, hides deeper methods too
+				byName[name] = Method{}
+			}
+		}
+		current = next
+	}
+	if !found {
+		return nil, fmt.Errorf("type %s %w", typeName, ErrNotFound)
+	}
+	for name, m := range wrappers {
+		if _, ok := byName[name]; !ok {
+			byName[name] = m
+		}
+	}
+
+	methods := make([]Method, 0, len(byName))
+	for _, m := range byName {
+		if m.Func == "" {
+			continue
+		}
+		f, err := d.findFunc(m.Func)
+		if err != nil {
+			continue
+		}
+		inTyps, outTyps, _, _, err := d.getFunctionArgTypes(f)
+		if err != nil || len(inTyps) == 0 {
+			continue
+		}
+		variadic, _ := d.funcVariadic(f, inTyps)
+		m.Type = reflect.FuncOf(inTyps[1:], outTyps, variadic)
+		methods = append(methods, m)
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+	return methods, nil
+}
+
+// declaredMethods lists the methods declared on typeName by their symbols,
+// "pkg.T.M" for value receivers and "pkg.(*T).M" for pointer receivers.
+func (d *DwarfRT) declaredMethods(typeName string) []Method {
+	pkg, local := splitPackage(typeName)
+	if pkg == "" || local == "" {
+		return nil
+	}
+	valuePrefix := pkg + "." + local + "."
+	ptrPrefix := pkg + ".(*" + local + ")."
+
+	methods := make(map[string]Method)
+	for i := range d.bi.Functions {
+		fn := &d.bi.Functions[i]
+		if fn.Entry == 0 {
+			continue
+		}
+		var name string
+		ptrRecv := false
+		switch {
+		case strings.HasPrefix(fn.Name, valuePrefix):
+			name = fn.Name[len(valuePrefix):]
+		case strings.HasPrefix(fn.Name, ptrPrefix):
+			name = fn.Name[len(ptrPrefix):]
+			ptrRecv = true
+		default:
+			continue
+		}
+		// skip closures and method value wrappers such as "M.func1" or "M-fm"
+		if name == "" || strings.ContainsAny(name, ".-") {
+			continue
+		}
+		// the compiler generates (*T).M wrappers for value receiver methods
+		if prev, ok := methods[name]; ok && !prev.PtrRecv {
+			continue
+		}
+		file, _ := d.funcDecl(fn)
+		methods[name] = Method{
+			Name:          name,
+			Func:          fn.Name,
+			Recv:          typeName,
+			PtrRecv:       ptrRecv,
+			Exported:      isExportedName(name),
+			autogenerated: file == "" || file == "<autogenerated>",
+		}
+	}
+
+	result := make([]Method, 0, len(methods))
+	for _, m := range methods {
+		result = append(result, m)
+	}
+	return result
+}
+
+// structType returns the DWARF struct type of typeName. d.dwarfMu must not be held.
+func (d *DwarfRT) structType(typeName string) (*godwarf.StructType, error) {
+	d.dwarfMu.Lock()
+	defer d.dwarfMu.Unlock()
+
+	dtyp, err := findType(d.bi, typeName)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		typedef, ok := dtyp.(*godwarf.TypedefType)
+		if !ok {
+			break
+		}
+		dtyp = typedef.Type
+	}
+	st, ok := dtyp.(*godwarf.StructType)
+	if !ok {
+		return nil, fmt.Errorf("type %s is not a struct", typeName)
+	}
+	return st, nil
+}
+
+// fieldAtOffset returns the settable field of struct v with the given name and offset.
+func fieldAtOffset(v reflect.Value, name string, offset int64) (reflect.Value, bool) {
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	typ := v.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Offset == uintptr(offset) && (field.Name == name || synthesizedFieldName(name, i) == field.Name) {
+			return writable(v.Field(i)), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// writable returns an addressable v that may be set and passed to Call even
+// when it was reached through unexported fields.
+func writable(v reflect.Value) reflect.Value {
+	if !v.CanAddr() {
+		return v
+	}
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
+
+// fullTypeName returns the DWARF name of a named type, e.g. "github.com/x/y.T".
+func fullTypeName(typ reflect.Type) string {
+	if typ.Name() == "" {
+		return ""
+	}
+	if typ.PkgPath() == "" {
+		return typ.Name()
+	}
+	return typ.PkgPath() + "." + typ.Name()
+}
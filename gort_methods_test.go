@@ -0,0 +1,114 @@
+package gort_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type methodBase struct {
+	id int
+}
+
+//go:noinline
+func (b methodBase) ID() int {
+	return b.id
+}
+
+//go:noinline
+func (b *methodBase) setID(id int) {
+	b.id = id
+}
+
+type methodStruct struct {
+	methodBase
+	str string
+}
+
+//go:noinline
+func (p *methodStruct) Format(format string, args ...interface{}) string {
+	return p.str + ":" + fmt.Sprintf(format, args...)
+}
+
+//go:noinline
+func (p methodStruct) Str() string {
+	return p.str
+}
+
+var (
+	methodGlobal = methodStruct{methodBase: methodBase{id: 7}, str: "global"}
+	// keeps the runtime types of the exported methods
+	methodIface interface{} = &methodGlobal
+)
+
+func TestMethods(t *testing.T) {
+	rt := newRT(t)
+	methodGlobal.setID(7)
+
+	methods, err := rt.Methods(testPkg + ".methodStruct")
+	if err != nil {
+		t.Fatalf("methods err %s", err)
+	}
+	byName := make(map[string]int)
+	for i, m := range methods {
+		byName[m.Name] = i
+	}
+	for _, name := range []string{"Format", "Str", "ID", "setID"} {
+		if _, ok := byName[name]; !ok {
+			t.Fatalf("method %s not in %+v", name, methods)
+		}
+	}
+	if m := methods[byName["setID"]]; m.Exported || !m.PtrRecv || m.Recv != testPkg+".methodBase" || len(m.Embedded) != 1 || m.Embedded[0] != "methodBase" {
+		t.Errorf("promoted method %+v", m)
+	}
+	if m := methods[byName["Str"]]; !m.Exported || m.PtrRecv || len(m.Embedded) != 0 || m.Type != reflect.TypeOf(func() string { return "" }) {
+		t.Errorf("declared method %+v", m)
+	}
+	if m := methods[byName["Format"]]; !m.Type.IsVariadic() {
+		t.Errorf("variadic method has type %s", m.Type)
+	}
+}
+
+func TestMethodByName(t *testing.T) {
+	rt := newRT(t)
+	v := methodStruct{methodBase: methodBase{id: 1}, str: "local"}
+
+	format, err := rt.MethodByName(reflect.ValueOf(&v), "Format")
+	if err != nil {
+		t.Fatalf("method Format err %s", err)
+	}
+	if !format.Type().IsVariadic() {
+		t.Errorf("method Format has type %s", format.Type())
+	}
+	out := format.Call([]reflect.Value{reflect.ValueOf("%d-%d"), reflect.ValueOf(2), reflect.ValueOf(3)})
+	if s := out[0].String(); s != "local:2-3" {
+		t.Errorf("Format returned %q", s)
+	}
+	out = format.CallSlice([]reflect.Value{reflect.ValueOf("%d"), reflect.ValueOf([]interface{}{4})})
+	if s := out[0].String(); s != "local:4" {
+		t.Errorf("Format with a slice returned %q", s)
+	}
+
+	setID, err := rt.MethodByName(reflect.ValueOf(&v), "setID")
+	if err != nil {
+		t.Fatalf("method setID err %s", err)
+	}
+	setID.Call([]reflect.Value{reflect.ValueOf(42)})
+	if v.id != 42 {
+		t.Errorf("setID through the embedded field set %d", v.id)
+	}
+
+	id, err := rt.MethodByName(reflect.ValueOf(v), "ID")
+	if err != nil {
+		t.Fatalf("method ID on a value err %s", err)
+	}
+	if n := id.Call(nil)[0].Int(); n != 42 {
+		t.Errorf("ID returned %d", n)
+	}
+	if _, err := rt.MethodByName(reflect.ValueOf(v), "setID"); err == nil {
+		t.Errorf("pointer method on an unaddressable value succeeded")
+	}
+	if _, err := rt.MethodByName(reflect.ValueOf(&v), "missing"); err == nil {
+		t.Errorf("missing method succeeded")
+	}
+}
@@ -0,0 +1,95 @@
+package gort
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// PatchHandle is an installed patch, Restore puts back the original function.
+type PatchHandle struct {
+	Func string
+	Addr uint64
+
+	original    []byte
+	replacement reflect.Value // *func, keeps the closure of the replacement alive
+}
+
+var (
+	patchesMu sync.Mutex
+	// patches maps patched entries to their *PatchHandle or *HookHandle
+	patches = make(map[uint64]interface{})
+)
+
+// Patch redirects every call to the function name to replacement, which must
+// have its signature, by writing a jump over the function entry. Calls the
+// compiler inlined are not redirected, build with -gcflags=all=-l. The entry
+// is rewritten in place, goroutines running it at that moment may crash.
+// Only linux/amd64 is supported.
+func (d *DwarfRT) Patch(name string, replacement reflect.Value) (*PatchHandle, error) {
+	if replacement.Kind() != reflect.Func || replacement.IsNil() {
+		return nil, fmt.Errorf("patch %s replacement is not a func", name)
+	}
+	ftyp := replacement.Type()
+
+	if err := d.rlock(); err != nil {
+		return nil, err
+	}
+	f, err := d.findFunc(name)
+	if err != nil {
+		d.mu.RUnlock()
+		return nil, err
+	}
+	inTyps, outTyps, _, _, err := d.getFunctionArgTypes(f)
+	d.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if !d.signatureMatches(ftyp, inTyps, outTyps) {
+		return nil, fmt.Errorf("patch %s signature %s does not match %s", name, reflect.FuncOf(inTyps, outTyps, false), ftyp)
+	}
+
+	holder := reflect.New(ftyp)
+	holder.Elem().Set(replacement)
+	funcval := *(*uintptr)(unsafe.Pointer(holder.Pointer()))
+	code := patchCode(funcval)
+	if code == nil {
+		return nil, fmt.Errorf("patch %w on this platform", ErrNotSupport)
+	}
+
+	patchesMu.Lock()
+	defer patchesMu.Unlock()
+	if _, ok := patches[f.Entry]; ok {
+		return nil, fmt.Errorf("patch %s is already patched", name)
+	}
+	if space := patchSpace(f.Entry, f.End, len(code)); space < uint64(len(code)) {
+		return nil, fmt.Errorf("patch %s has room for %d bytes, the jump needs %d", name, space, len(code))
+	}
+	h := &PatchHandle{Func: f.Name, Addr: f.Entry, replacement: holder}
+	h.original = append([]byte(nil), textBytes(f.Entry, len(code))...)
+	if err := writeText(f.Entry, code); err != nil {
+		return nil, err
+	}
+	patches[f.Entry] = h
+	return h, nil
+}
+
+// Restore writes back the original entry of the patched function.
+func (h *PatchHandle) Restore() error {
+	patchesMu.Lock()
+	defer patchesMu.Unlock()
+	if p, ok := patches[h.Addr].(*PatchHandle); !ok || p != h {
+		return fmt.Errorf("patch %s is not installed", h.Func)
+	}
+	if err := writeText(h.Addr, h.original); err != nil {
+		return err
+	}
+	delete(patches, h.Addr)
+	return nil
+}
+
+// textBytes returns n bytes of code at addr.
+func textBytes(addr uint64, n int) []byte {
+	return unsafe.Slice((*byte)(addrPointer(addr)), n)
+}
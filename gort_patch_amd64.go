@@ -0,0 +1,41 @@
+//go:build linux && amd64
+
+package gort
+
+import (
+	"syscall"
+)
+
+// patchCode returns the jump to the func value funcval, the closure context
+// register DX is set like the compiler does for calls through func values.
+func patchCode(funcval uintptr) []byte {
+	return []byte{
+		0x48, 0xba, // MOVQ $funcval, DX
+		byte(funcval), byte(funcval >> 8), byte(funcval >> 16), byte(funcval >> 24),
+		byte(funcval >> 32), byte(funcval >> 40), byte(funcval >> 48), byte(funcval >> 56),
+		0xff, 0x22, // JMP (DX)
+	}
+}
+
+// patchSpace returns the bytes that can be overwritten at the entry of the
+// function [entry, end), including the int3 padding after it, up to n.
+func patchSpace(entry, end uint64, n int) uint64 {
+	space := end - entry
+	for space < uint64(n) && textBytes(entry+space, 1)[0] == 0xcc {
+		space++
+	}
+	return space
+}
+
+// writeText copies code to addr, making the pages writable for the time of the copy.
+func writeText(addr uint64, code []byte) error {
+	pageSize := uint64(syscall.Getpagesize())
+	start := addr &^ (pageSize - 1)
+	end := (addr + uint64(len(code)) + pageSize - 1) &^ (pageSize - 1)
+	pages := textBytes(start, int(end-start))
+	if err := syscall.Mprotect(pages, syscall.PROT_READ|syscall.PROT_WRITE|syscall.PROT_EXEC); err != nil {
+		return err
+	}
+	copy(textBytes(addr, len(code)), code)
+	return syscall.Mprotect(pages, syscall.PROT_READ|syscall.PROT_EXEC)
+}
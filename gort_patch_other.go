@@ -0,0 +1,34 @@
+//go:build !linux || !amd64
+
+package gort
+
+func patchCode(funcval uintptr) []byte {
+	return nil
+}
+
+func writeText(addr uint64, code []byte) error {
+	return ErrNotSupport
+}
+
+func patchSpace(entry, end uint64, n int) uint64 {
+	return end - entry
+}
+
+type hookTrampoline struct {
+	relocated uint64
+}
+
+func newHookTrampoline(entry, end uint64) (*hookTrampoline, error) {
+	return nil, ErrNotSupport
+}
+
+func (t *hookTrampoline) install(entry uint64, funcval uintptr) error {
+	return ErrNotSupport
+}
+
+func (t *hookTrampoline) uninstall(entry uint64) error {
+	return ErrNotSupport
+}
+
+func (t *hookTrampoline) release() {
+}
@@ -0,0 +1,69 @@
+package gort_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/lsg2020/gort"
+)
+
+//go:noinline
+func patchAdd(a, b int) int {
+	return a + b
+}
+
+type patchT struct {
+	n int
+}
+
+//go:noinline
+func (p *patchT) get() int {
+	return p.n
+}
+
+func TestPatch(t *testing.T) {
+	rt := newRT(t)
+	k := 100
+	h, err := rt.Patch(testPkg+".patchAdd", reflect.ValueOf(func(a, b int) int { return a*b + k }))
+	if errors.Is(err, gort.ErrNotSupport) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("patch err %s", err)
+	}
+	if n := patchAdd(3, 4); n != 112 {
+		t.Errorf("patched patchAdd returned %d", n)
+	}
+	if _, err := rt.Patch(testPkg+".patchAdd", reflect.ValueOf(func(a, b int) int { return 0 })); err == nil {
+		t.Errorf("second patch succeeded")
+	}
+	if err := h.Restore(); err != nil {
+		t.Fatalf("restore err %s", err)
+	}
+	if n := patchAdd(3, 4); n != 7 {
+		t.Errorf("restored patchAdd returned %d", n)
+	}
+	if err := h.Restore(); err == nil {
+		t.Errorf("second restore succeeded")
+	}
+
+	if _, err := rt.Patch(testPkg+".patchAdd", reflect.ValueOf(func(a int) int { return 0 })); err == nil {
+		t.Errorf("patch with another signature succeeded")
+	}
+
+	p := &patchT{5}
+	h, err = rt.Patch(testPkg+".(*patchT).get", reflect.ValueOf(func(p *patchT) int { return -p.n }))
+	if err != nil {
+		t.Fatalf("patch method err %s", err)
+	}
+	if n := p.get(); n != -5 {
+		t.Errorf("patched get returned %d", n)
+	}
+	if err := h.Restore(); err != nil {
+		t.Fatalf("restore method err %s", err)
+	}
+	if n := p.get(); n != 5 {
+		t.Errorf("restored get returned %d", n)
+	}
+}
@@ -11,7 +11,12 @@ import (
 )
 
 func (d *DwarfRT) SearchPluginByName(name string) (string, uint64, error) {
-	libs, addr, err := d.SearchPlugins()
+	if err := d.rlock(); err != nil {
+		return "", 0, err
+	}
+	defer d.mu.RUnlock()
+
+	libs, addr, err := d.searchPlugins()
 	if err != nil {
 		return "", 0, err
 	}
@@ -24,9 +29,15 @@ func (d *DwarfRT) SearchPluginByName(name string) (string, uint64, error) {
 }
 
 func (d *DwarfRT) SearchPlugins() ([]string, []uint64, error) {
-	if err := d.check(); err != nil {
+	if err := d.rlock(); err != nil {
 		return nil, nil, err
 	}
+	defer d.mu.RUnlock()
+
+	return d.searchPlugins()
+}
+
+func (d *DwarfRT) searchPlugins() ([]string, []uint64, error) {
 	bi := d.bi
 
 	if bi.ElfDynamicSection.Addr == 0 {
@@ -0,0 +1,232 @@
+package gort
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-delve/delve/pkg/proc"
+)
+
+// Kind selects what Search looks at, kinds may be or'ed together.
+type Kind int
+
+const (
+	KindType Kind = 1 << iota
+	KindFunc
+	KindGlobal
+
+	KindAll = KindType | KindFunc | KindGlobal
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindType:
+		return "type"
+	case KindFunc:
+		return "func"
+	case KindGlobal:
+		return "global"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// SearchOptions refines a Search, the zero value matches everything.
+type SearchOptions struct {
+	// Regexp interprets the pattern as a regular expression instead of a glob.
+	Regexp bool
+	// Packages restricts results to these package paths,
+	// a "/..." suffix also matches the sub packages.
+	Packages []string
+	// Offset and Limit select a page of the sorted results, Limit 0 means no limit.
+	Offset int
+	Limit  int
+}
+
+// SearchResult describes one matching type, function or global.
+type SearchResult struct {
+	Kind     Kind
+	Name     string // full name, e.g. "github.com/x/y.(*T).M"
+	Package  string // package path, e.g. "github.com/x/y"
+	File     string // declaring file, functions only
+	Line     int    // declaring line, functions only
+	Exported bool
+	Generic  bool // an instantiation of a generic type or function
+	Method   bool
+
+	fn *proc.Function
+}
+
+// Search returns the sorted page of types, functions and globals whose full
+// name matches pattern and the total number of matches. Patterns are globs
+// where '*' matches any sequence, including '/' and '.', unless opts.Regexp is set.
+func (d *DwarfRT) Search(kind Kind, pattern string, opts *SearchOptions) ([]SearchResult, int, error) {
+	if opts == nil {
+		opts = &SearchOptions{}
+	}
+	match, err := compilePattern(pattern, opts.Regexp)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := d.rlock(); err != nil {
+		return nil, 0, err
+	}
+	defer d.mu.RUnlock()
+
+	var results []SearchResult
+	add := func(r SearchResult) {
+		if !match(r.Name) || !matchPackages(r.Package, opts.Packages) {
+			return
+		}
+		results = append(results, r)
+	}
+
+	types, err := d.bi.Types()
+	if err != nil {
+		return nil, 0, err
+	}
+	typeNames := make(map[string]bool, len(types))
+	for _, name := range types {
+		typeNames[name] = true
+	}
+
+	if kind&KindType != 0 {
+		for _, name := range types {
+			expr, err := parseTypeExpr(name)
+			if err != nil || expr.kind != typeExprNamed {
+				continue
+			}
+			pkg, local := splitPackage(name)
+			add(SearchResult{
+				Kind:     KindType,
+				Name:     name,
+				Package:  pkg,
+				Exported: isExportedName(local),
+				Generic:  typeArgs(name) != nil,
+			})
+		}
+	}
+
+	if kind&KindFunc != 0 {
+		for i := range d.bi.Functions {
+			fn := &d.bi.Functions[i]
+			if fn.Entry == 0 {
+				continue
+			}
+			pkg, local := splitPackage(fn.Name)
+			method := false
+			if dot := strings.LastIndexByte(stripTypeArgs(local), '.'); dot >= 0 {
+				recv := strings.TrimSuffix(strings.TrimPrefix(stripTypeArgs(local)[:dot], "(*"), ")")
+				method = typeNames[pkg+"."+recv] || strings.HasPrefix(local, "(*")
+				local = local[strings.LastIndexByte(local, '.')+1:]
+			}
+			add(SearchResult{
+				Kind:     KindFunc,
+				Name:     fn.Name,
+				Package:  pkg,
+				Exported: isExportedName(local),
+				Generic:  typeArgs(fn.Name) != nil,
+				Method:   method,
+				fn:       fn,
+			})
+		}
+	}
+
+	if kind&KindGlobal != 0 {
+		for name := range d.loadGlobals() {
+			// globals of other images are qualified, e.g. "libfoo.so:pkg.V"
+			pkg, local := splitPackage(name[strings.IndexByte(name, ':')+1:])
+			add(SearchResult{
+				Kind:     KindGlobal,
+				Name:     name,
+				Package:  pkg,
+				Exported: isExportedName(local),
+			})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Name != results[j].Name {
+			return results[i].Name < results[j].Name
+		}
+		return results[i].Kind < results[j].Kind
+	})
+
+	total := len(results)
+	if opts.Offset > 0 {
+		if opts.Offset >= len(results) {
+			results = nil
+		} else {
+			results = results[opts.Offset:]
+		}
+	}
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	for i := range results {
+		if results[i].fn != nil {
+			results[i].File, results[i].Line = d.funcDecl(results[i].fn)
+			results[i].fn = nil
+		}
+	}
+	return results, total, nil
+}
+
+// compilePattern returns a matcher for a glob or regular expression, an empty pattern matches everything.
+func compilePattern(pattern string, isRegexp bool) (func(string) bool, error) {
+	if pattern == "" {
+		return func(string) bool { return true }, nil
+	}
+	if !isRegexp {
+		var b strings.Builder
+		b.WriteString("^")
+		for _, r := range pattern {
+			switch r {
+			case '*':
+				b.WriteString(".*")
+			case '?':
+				b.WriteString(".")
+			default:
+				b.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+		b.WriteString("$")
+		pattern = b.String()
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString, nil
+}
+
+// matchPackages reports whether pkg matches one of the package patterns, no patterns match everything.
+func matchPackages(pkg string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if pattern == "..." {
+			return true
+		}
+		if prefix := strings.TrimSuffix(pattern, "/..."); prefix != pattern {
+			if pkg == prefix || strings.HasPrefix(pkg, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if pkg == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func isExportedName(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r)
+}
@@ -0,0 +1,82 @@
+package gort_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/lsg2020/gort"
+)
+
+type searchType struct {
+	n int
+}
+
+var searchGlobal = &searchType{n: 1}
+
+//go:noinline
+func searchFunc(s *searchType) int {
+	return s.n
+}
+
+//go:noinline
+func (s *searchType) SearchMethod() int {
+	return s.n
+}
+
+func TestSearch(t *testing.T) {
+	rt := newRT(t)
+	if searchFunc(searchGlobal)+searchGlobal.SearchMethod() != 2 {
+		t.Fatalf("fixtures changed")
+	}
+
+	results, total, err := rt.Search(gort.KindAll, testPkg+".*search*", nil)
+	if err != nil {
+		t.Fatalf("search err %s", err)
+	}
+	if total != len(results) {
+		t.Errorf("total %d, %d results", total, len(results))
+	}
+	found := make(map[string]gort.SearchResult)
+	for _, r := range results {
+		found[r.Name] = r
+	}
+	if r, ok := found[testPkg+".searchFunc"]; !ok || r.Kind != gort.KindFunc || r.Package != testPkg || r.Exported ||
+		filepath.Base(r.File) != "gort_search_test.go" || r.Line == 0 {
+		t.Errorf("function result %+v", r)
+	}
+	if r, ok := found[testPkg+".(*searchType).SearchMethod"]; !ok || !r.Method || !r.Exported {
+		t.Errorf("method result %+v", r)
+	}
+	if r, ok := found[testPkg+".searchGlobal"]; !ok || r.Kind != gort.KindGlobal {
+		t.Errorf("global result %+v", r)
+	}
+	if r, ok := found[testPkg+".searchType"]; !ok || r.Kind != gort.KindType {
+		t.Errorf("type result %+v", r)
+	}
+
+	results, _, err = rt.Search(gort.KindFunc, `^`+testPkg+`\.search[A-Z]\w+$`, &gort.SearchOptions{Regexp: true})
+	if err != nil {
+		t.Fatalf("regexp search err %s", err)
+	}
+	if len(results) != 1 || results[0].Name != testPkg+".searchFunc" {
+		t.Errorf("regexp search results %+v", results)
+	}
+
+	page, total, err := rt.Search(gort.KindFunc, "*", &gort.SearchOptions{Packages: []string{testPkg}, Offset: 1, Limit: 2})
+	if err != nil {
+		t.Fatalf("paged search err %s", err)
+	}
+	all, _, _ := rt.Search(gort.KindFunc, "*", &gort.SearchOptions{Packages: []string{testPkg}})
+	if total != len(all) || len(page) != 2 || page[0].Name != all[1].Name || page[1].Name != all[2].Name {
+		t.Errorf("page %+v of %d results", page, total)
+	}
+	for _, r := range all {
+		if r.Package != testPkg {
+			t.Errorf("result %s outside of %s", r.Name, testPkg)
+		}
+	}
+
+	if _, _, err := rt.Search(gort.KindAll, "(", &gort.SearchOptions{Regexp: true}); err == nil {
+		t.Errorf("invalid regexp succeeded")
+	}
+}
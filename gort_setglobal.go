@@ -0,0 +1,114 @@
+package gort
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"unsafe"
+)
+
+// SetGlobal stores value, an interface{} or a reflect.Value, into the global
+// name and returns a copy of its previous value. The value must be assignable
+// to the DWARF type of the global, numbers convert between kinds when they are
+// represented exactly and nil sets the zero value of pointers, maps, slices,
+// channels, funcs and interfaces. Word sized numbers and pointer shaped values
+// are stored atomically, other values are copied without synchronization.
+func (d *DwarfRT) SetGlobal(name string, value interface{}) (reflect.Value, error) {
+	v, err := d.FindGlobal(name)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("set %s: %w", name, err)
+	}
+	return setValue(name, v, value)
+}
+
+// SetGlobalPath is SetGlobal for a field of a global, e.g. "main.cfg.timeouts.read",
+// the field path after the global name is resolved as by FieldByPath.
+func (d *DwarfRT) SetGlobalPath(path string, value interface{}) (reflect.Value, error) {
+	v, err := d.globalPath(path)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("set %s: %w", path, err)
+	}
+	return setValue(path, v, value)
+}
+
+// globalPath returns the global or the field of a global at path, such as
+// "libfoo.so:main.cfg.items[2].name".
+func (d *DwarfRT) globalPath(path string) (reflect.Value, error) {
+	image := path[:strings.IndexByte(path, ':')+1] // e.g. "libfoo.so:"
+	pkg, local := splitPackage(path[len(image):])
+	name, fields := local, ""
+	if dot := strings.IndexAny(local, ".["); dot >= 0 {
+		name, fields = local[:dot], strings.TrimPrefix(local[dot:], ".")
+	}
+	if pkg != "" {
+		name = pkg + "." + name
+	}
+	name = image + name
+	v, err := d.FindGlobal(name)
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("global %s: %w", name, err)
+	}
+	if fields == "" {
+		return v, nil
+	}
+	return FieldByPath(v, fields)
+}
+
+// setValue stores value into the addressable dst and returns the previous value.
+func setValue(name string, dst reflect.Value, value interface{}) (reflect.Value, error) {
+	if !dst.CanAddr() {
+		return reflect.Value{}, fmt.Errorf("set %s: not addressable", name)
+	}
+	typ := dst.Type()
+	src, ok := value.(reflect.Value)
+	if !ok {
+		src = reflect.ValueOf(value)
+	}
+	switch {
+	case !src.IsValid():
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func, reflect.Interface, reflect.UnsafePointer:
+			src = reflect.Zero(typ)
+		default:
+			return reflect.Value{}, fmt.Errorf("set %s: nil is not assignable to %s", name, typ)
+		}
+	case src.Type().AssignableTo(typ):
+	case isNumber(src.Kind()) && isNumber(typ.Kind()) && !isComplex(src.Kind()) && !isComplex(typ.Kind()):
+		v, err := coerceNumber(src, typ, func(format string, args ...interface{}) (reflect.Value, error) {
+			return reflect.Value{}, fmt.Errorf(format, args...)
+		})
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("set %s: %s to %s: %w", name, src.Type(), typ, err)
+		}
+		src = v
+	default:
+		return reflect.Value{}, fmt.Errorf("set %s: %s is not assignable to %s", name, src.Type(), typ)
+	}
+	return storeValue(writable(dst), copyValue(src, typ)), nil
+}
+
+// storeValue stores src into the settable dst and returns the previous value,
+// atomically for 4 and 8 byte numbers and pointer shaped values.
+func storeValue(dst, src reflect.Value) reflect.Value {
+	typ := dst.Type()
+	p := unsafe.Pointer(dst.UnsafeAddr())
+	tmp := reflect.New(typ)
+	tmp.Elem().Set(src)
+	q := unsafe.Pointer(tmp.Pointer())
+
+	switch kind := typ.Kind(); {
+	case kind == reflect.Ptr || kind == reflect.Map || kind == reflect.Chan || kind == reflect.Func || kind == reflect.UnsafePointer:
+		*(*unsafe.Pointer)(q) = atomic.SwapPointer((*unsafe.Pointer)(p), *(*unsafe.Pointer)(q))
+	case isNumber(kind) && typ.Size() == 8 && uintptr(p)%8 == 0:
+		*(*uint64)(q) = atomic.SwapUint64((*uint64)(p), *(*uint64)(q))
+	case isNumber(kind) && typ.Size() == 4 && uintptr(p)%4 == 0:
+		*(*uint32)(q) = atomic.SwapUint32((*uint32)(p), *(*uint32)(q))
+	default:
+		prev := reflect.New(typ).Elem()
+		prev.Set(dst)
+		dst.Set(src)
+		return prev
+	}
+	return tmp.Elem()
+}
@@ -0,0 +1,86 @@
+package gort_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type setTimeouts struct {
+	read, write time.Duration
+}
+
+type setConfig struct {
+	name     string
+	timeouts setTimeouts
+	limits   []int64
+	hook     func()
+}
+
+var (
+	setLimit  = 10
+	setCfg    = &setConfig{name: "cfg", timeouts: setTimeouts{read: time.Second}, limits: []int64{1, 2}}
+	setRatio  float32
+	setLogger interface{}
+)
+
+func TestSetGlobal(t *testing.T) {
+	rt := newRT(t)
+	if setLimit != 10 || setCfg.name != "cfg" || setRatio != 0 || setLogger != nil {
+		t.Fatalf("fixtures changed")
+	}
+
+	prev, err := rt.SetGlobal(testPkg+".setLimit", 100)
+	if err != nil {
+		t.Fatalf("set global err %s", err)
+	}
+	if prev.Int() != 10 || setLimit != 100 {
+		t.Errorf("set global from %v to %d", prev, setLimit)
+	}
+	// numbers convert when they are represented exactly
+	if _, err := rt.SetGlobal(testPkg+".setLimit", int8(5)); err != nil || setLimit != 5 {
+		t.Errorf("set global from int8 to %d err %v", setLimit, err)
+	}
+	if _, err := rt.SetGlobal(testPkg+".setRatio", 0.5); err != nil || setRatio != 0.5 {
+		t.Errorf("set float32 global to %v err %v", setRatio, err)
+	}
+	if _, err := rt.SetGlobal(testPkg+".setRatio", 1e40); err == nil {
+		t.Errorf("set float32 global to an overflowing value succeeded")
+	}
+	if _, err := rt.SetGlobal(testPkg+".setLimit", 1.5); err == nil {
+		t.Errorf("set int global to a fraction succeeded")
+	}
+	if _, err := rt.SetGlobal(testPkg+".setLimit", "5"); err == nil {
+		t.Errorf("set int global to a string succeeded")
+	}
+	if _, err := rt.SetGlobal(testPkg+".setLogger", 3); err != nil || setLogger != 3 {
+		t.Errorf("set interface global to %v err %v", setLogger, err)
+	}
+	if _, err := rt.SetGlobal(testPkg+".setLogger", nil); err != nil || setLogger != nil {
+		t.Errorf("set interface global to nil gave %v err %v", setLogger, err)
+	}
+
+	prev, err = rt.SetGlobalPath(testPkg+".setCfg.timeouts.read", 3*time.Second)
+	if err != nil {
+		t.Fatalf("set field err %s", err)
+	}
+	if prev.Interface() != time.Second || setCfg.timeouts.read != 3*time.Second {
+		t.Errorf("set field from %v to %v", prev, setCfg.timeouts.read)
+	}
+	if _, err := rt.SetGlobalPath(testPkg+".setCfg.limits[1]", 7); err != nil || setCfg.limits[1] != 7 {
+		t.Errorf("set element to %d err %v", setCfg.limits[1], err)
+	}
+	prev, err = rt.SetGlobalPath(testPkg+".setCfg.limits", reflect.ValueOf([]int64{9}))
+	if err != nil || !reflect.DeepEqual(setCfg.limits, []int64{9}) || !reflect.DeepEqual(prev.Interface(), []int64{1, 7}) {
+		t.Errorf("set slice field from %v to %v err %v", prev, setCfg.limits, err)
+	}
+	if _, err := rt.SetGlobalPath(testPkg+".setCfg.hook", nil); err != nil || setCfg.hook != nil {
+		t.Errorf("set func field to nil err %v", err)
+	}
+	if _, err := rt.SetGlobalPath(testPkg+".setCfg.missing", 1); err == nil {
+		t.Errorf("set missing field succeeded")
+	}
+	if _, err := rt.SetGlobal(testPkg+".missing", 1); err == nil {
+		t.Errorf("set missing global succeeded")
+	}
+}
@@ -0,0 +1,202 @@
+package gort
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-delve/delve/pkg/proc"
+)
+
+// FuncParam is a parameter or result of a function.
+type FuncParam struct {
+	Name     string // "~p0" or "~r0" style names for unnamed parameters and results
+	TypeName string // DWARF type name
+	Type     reflect.Type
+}
+
+// FuncSignature is the signature of a function read from DWARF.
+type FuncSignature struct {
+	Name string
+	Recv string // receiver type of a method, e.g. "*main.testStruct", the receiver is also In[0]
+	In   []FuncParam
+	Out  []FuncParam
+	// Variadic reports whether the last parameter is variadic, it is read from
+	// DW_TAG_unspecified_parameters and the runtime method type. The Go
+	// compiler records neither for functions, closures and unexported
+	// methods, VariadicKnown is then false unless the last parameter is no slice.
+	Variadic      bool
+	VariadicKnown bool
+	File          string
+	Line          int
+}
+
+// Type returns the func type of the signature, with the receiver as first parameter.
+func (s *FuncSignature) Type() reflect.Type {
+	in := make([]reflect.Type, len(s.In))
+	for i, param := range s.In {
+		in[i] = param.Type
+	}
+	out := make([]reflect.Type, len(s.Out))
+	for i, param := range s.Out {
+		out[i] = param.Type
+	}
+	return reflect.FuncOf(in, out, s.Variadic)
+}
+
+// Signature returns the signature of the function name.
+func (d *DwarfRT) Signature(name string) (*FuncSignature, error) {
+	if err := d.rlock(); err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	f, err := d.findFunc(name)
+	if err != nil {
+		return nil, err
+	}
+	return d.signature(f)
+}
+
+// CallFuncNamed calls the function name with the arguments keyed by parameter
+// name, omitted parameters are passed as zero values. A variadic parameter
+// takes a slice, the receiver of a method is passed by its parameter name.
+func (d *DwarfRT) CallFuncNamed(name string, args map[string]reflect.Value) ([]reflect.Value, error) {
+	sig, err := d.Signature(name)
+	if err != nil {
+		return nil, err
+	}
+	in := make([]reflect.Value, len(sig.In))
+	used := 0
+	for i, param := range sig.In {
+		if arg, ok := args[param.Name]; ok {
+			in[i] = arg
+			used++
+		} else {
+			in[i] = reflect.Zero(param.Type)
+		}
+	}
+	if used != len(args) {
+		for argName := range args {
+			if !sig.hasParam(argName) {
+				return nil, &CallError{Func: name, Arg: -1, Err: fmt.Errorf("no parameter %s", argName)}
+			}
+		}
+	}
+	return d.CallFunc(name, false, in)
+}
+
+func (s *FuncSignature) hasParam(name string) bool {
+	for _, param := range s.In {
+		if param.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// signature reads the signature of f. d.mu must be held.
+func (d *DwarfRT) signature(f *proc.Function) (*FuncSignature, error) {
+	params, err := d.funcParams(f)
+	if err != nil {
+		return nil, err
+	}
+	sig := &FuncSignature{Name: f.Name}
+	var in []reflect.Type
+	for _, param := range params {
+		typ, err := d.lookupType(param.typeName)
+		if err != nil {
+			return nil, fmt.Errorf("signature of %s: %w", f.Name, err)
+		}
+		p := FuncParam{Name: param.name, TypeName: param.typeName, Type: typ}
+		if param.ret {
+			sig.Out = append(sig.Out, p)
+		} else {
+			sig.In = append(sig.In, p)
+			in = append(in, typ)
+		}
+	}
+	sig.File, sig.Line = d.funcDecl(f)
+
+	recv, method := methodReceiver(f.Name)
+	if method != "" && !strings.HasSuffix(method, "-fm") && len(sig.In) > 0 {
+		// method value wrappers take the receiver from their closure
+		sig.Recv = recv
+	}
+	sig.Variadic, sig.VariadicKnown = d.funcVariadic(f, in)
+	return sig, nil
+}
+
+// funcVariadic reports whether the last of the input parameters in of f is
+// variadic, ok is false when the binary does not tell. d.mu must be held.
+func (d *DwarfRT) funcVariadic(f *proc.Function, in []reflect.Type) (variadic bool, ok bool) {
+	if len(in) == 0 || in[len(in)-1].Kind() != reflect.Slice {
+		return false, true
+	}
+	if params, err := d.funcParams(f); err == nil {
+		for _, param := range params {
+			if param.variadic {
+				return true, true
+			}
+		}
+	}
+	return d.methodVariadic(f.Name)
+}
+
+// methodVariadic reports whether the method or method value wrapper name is
+// variadic from the runtime type of the method, ok is false for functions,
+// unexported methods and receivers without a runtime type. d.mu must be held.
+func (d *DwarfRT) methodVariadic(name string) (variadic bool, ok bool) {
+	recv, method := methodReceiver(strings.TrimSuffix(name, "-fm"))
+	if method == "" {
+		return false, false
+	}
+	typ, err := d.lookupType(recv)
+	if err != nil {
+		return false, false
+	}
+	return runtimeMethodVariadic(typ, method)
+}
+
+// runtimeMethodVariadic reports whether the method name of the runtime type
+// typ is variadic, ok is false when typ has no such exported method.
+func runtimeMethodVariadic(typ reflect.Type, name string) (variadic bool, ok bool) {
+	// the linker drops the type of methods unreachable through reflection,
+	// reflect panics on their nil method type
+	defer func() {
+		if recover() != nil {
+			variadic, ok = false, false
+		}
+	}()
+	m, ok := typ.MethodByName(name)
+	if !ok {
+		return false, false
+	}
+	return m.Type.IsVariadic(), true
+}
+
+// methodReceiver splits a method symbol such as "main.(*T).m" into the
+// receiver type "*main.T" and the method name, method is empty for functions.
+func methodReceiver(name string) (string, string) {
+	pkg, local := splitPackage(name)
+	ptr := ""
+	if strings.HasPrefix(local, "(*") {
+		end := strings.Index(local, ").")
+		if end < 0 {
+			return "", ""
+		}
+		ptr, local = "*", local[2:end]+local[end+1:]
+	}
+	parts := strings.Split(local, ".")
+	if len(parts) != 2 || isClosureName(parts[1]) {
+		// functions, and closures such as main.f.func1
+		return "", ""
+	}
+	return ptr + pkg + "." + parts[0], parts[1]
+}
+
+// isClosureName reports whether name is a compiler generated closure name such as "func1".
+func isClosureName(name string) bool {
+	return strings.HasPrefix(name, "func") && len(name) > len("func") &&
+		strings.Trim(name[len("func"):], "0123456789") == ""
+}
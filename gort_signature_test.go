@@ -0,0 +1,95 @@
+package gort_test
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/lsg2020/gort"
+)
+
+//go:noinline
+func sigHandle(name string, count int, tags ...string) (res string, err error) {
+	return fmt.Sprintf("%s %d %v", name, count, tags), nil
+}
+
+//go:noinline
+func (j joiner) add(delta int) int {
+	return j.n + delta
+}
+
+func TestSignature(t *testing.T) {
+	rt := newRT(t)
+	sigHandle("", 0)
+	joiner{}.add(1)
+
+	sig, err := rt.Signature(testPkg + ".sigHandle")
+	if err != nil {
+		t.Fatalf("signature err %s", err)
+	}
+	var in, out []string
+	for _, p := range sig.In {
+		in = append(in, p.Name+" "+p.TypeName)
+	}
+	for _, p := range sig.Out {
+		out = append(out, p.Name+" "+p.TypeName)
+	}
+	if !reflect.DeepEqual(in, []string{"name string", "count int", "tags []string"}) || !reflect.DeepEqual(out, []string{"res string", "err error"}) {
+		t.Errorf("signature in %v out %v", in, out)
+	}
+	if sig.Recv != "" || filepath.Base(sig.File) != "gort_signature_test.go" || sig.Line == 0 {
+		t.Errorf("signature %+v", sig)
+	}
+	// functions do not record whether they are variadic
+	if sig.VariadicKnown {
+		t.Errorf("variadic known for a function")
+	}
+
+	tests := []struct {
+		name            string
+		recv            string
+		variadic, known bool
+	}{
+		{testPkg + ".(*joiner).Join", "*" + testPkg + ".joiner", true, true},
+		{testPkg + ".(*joiner).Split", "*" + testPkg + ".joiner", false, true},
+		{testPkg + ".joiner.add", testPkg + ".joiner", false, true},
+		{testPkg + ".joinPair", "", false, true},
+	}
+	for _, test := range tests {
+		sig, err := rt.Signature(test.name)
+		if err != nil {
+			t.Errorf("signature %s err %s", test.name, err)
+			continue
+		}
+		if sig.Recv != test.recv || sig.Variadic != test.variadic || sig.VariadicKnown != test.known {
+			t.Errorf("signature %s recv %q variadic %v known %v", test.name, sig.Recv, sig.Variadic, sig.VariadicKnown)
+		}
+		if typ := sig.Type(); typ.IsVariadic() != test.variadic || typ.NumIn() != len(sig.In) {
+			t.Errorf("signature %s type %s", test.name, typ)
+		}
+	}
+}
+
+func TestCallFuncNamed(t *testing.T) {
+	rt := newRT(t)
+	rets, err := rt.CallFuncNamed(testPkg+".sigHandle", map[string]reflect.Value{
+		"name": reflect.ValueOf("x"),
+		"tags": reflect.ValueOf([]string{"a", "b"}),
+	})
+	if err != nil {
+		t.Fatalf("call err %s", err)
+	}
+	if s := rets[0].String(); s != "x 0 [a b]" {
+		t.Errorf("sigHandle returned %q", s)
+	}
+
+	var callErr *gort.CallError
+	if _, err := rt.CallFuncNamed(testPkg+".sigHandle", map[string]reflect.Value{"bogus": reflect.ValueOf(1)}); !errors.As(err, &callErr) {
+		t.Errorf("unknown parameter err %v", err)
+	}
+	if _, err := rt.CallFuncNamed(testPkg+".sigHandle", map[string]reflect.Value{"count": reflect.ValueOf("1")}); !errors.As(err, &callErr) || callErr.Arg != 1 {
+		t.Errorf("mistyped parameter err %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+package gort
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// GlobalSnapshot is a copy of the globals of some packages, Restore writes it back.
+type GlobalSnapshot struct {
+	Globals []string        // names of the globals copied
+	Skipped []SkippedGlobal // globals that could not be copied, Restore leaves them alone
+
+	saved []savedValue
+}
+
+// SkippedGlobal is a global Snapshot could not copy.
+type SkippedGlobal struct {
+	Name string
+	Err  error
+}
+
+// savedValue is the content of a location reachable from a global at the time of the snapshot.
+type savedValue struct {
+	kind  savedKind
+	loc   reflect.Value
+	value reflect.Value
+}
+
+type savedKind int
+
+const (
+	savedLocation savedKind = iota // loc is settable, value a shallow copy of it
+	savedElems                     // loc is a slice, value a copy of its elements
+	savedEntries                   // loc is a map, value a copy of its entries
+)
+
+// Snapshot copies the globals of the packages matching one of the package
+// patterns, a "/..." suffix also matches the sub packages, unexported globals
+// included. The copy is deep: the globals and every value reachable from them
+// through pointers, slices, maps and interfaces are saved, and Restore writes
+// them back in place so pointers keep their identity. Globals containing
+// channels, funcs, unsafe pointers or values of the sync package can not be
+// copied and are listed in Skipped. Restore must not run concurrently with
+// code using the globals.
+func (d *DwarfRT) Snapshot(packages ...string) (*GlobalSnapshot, error) {
+	if len(packages) == 0 {
+		return nil, fmt.Errorf("snapshot: no packages")
+	}
+	if err := d.rlock(); err != nil {
+		return nil, err
+	}
+	globals := d.loadGlobals()
+	d.mu.RUnlock()
+
+	names := make([]string, 0, len(globals))
+	for name := range globals {
+		// globals of other images are qualified, e.g. "libfoo.so:pkg.V"
+		pkg, _ := splitPackage(name[strings.IndexByte(name, ':')+1:])
+		if matchPackages(pkg, packages) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	snap := &GlobalSnapshot{}
+	for _, name := range names {
+		s := &globalSaver{seen: make(map[savedRef]bool)}
+		v := globals[name]
+		s.save(v)
+		if err := s.walk(v, ""); err != nil {
+			snap.Skipped = append(snap.Skipped, SkippedGlobal{Name: name, Err: err})
+			continue
+		}
+		snap.Globals = append(snap.Globals, name)
+		snap.saved = append(snap.saved, s.saved...)
+	}
+	return snap, nil
+}
+
+// Restore writes the saved globals and the values reachable from them back.
+func (s *GlobalSnapshot) Restore() {
+	for _, saved := range s.saved {
+		switch saved.kind {
+		case savedElems:
+			reflect.Copy(saved.loc, saved.value)
+		case savedEntries:
+			iter := saved.loc.MapRange()
+			for iter.Next() {
+				saved.loc.SetMapIndex(iter.Key(), reflect.Value{})
+			}
+			iter = saved.value.MapRange()
+			for iter.Next() {
+				saved.loc.SetMapIndex(iter.Key(), iter.Value())
+			}
+		default:
+			saved.loc.Set(saved.value)
+		}
+	}
+}
+
+// globalSaver collects the values reachable from one global.
+type globalSaver struct {
+	saved []savedValue
+	seen  map[savedRef]bool // pointers, slice arrays and maps saved
+}
+
+// savedRef identifies a saved pointer, slice array or map, a pointer to the
+// first element of an array has the address of a slice of it.
+type savedRef struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// save records a shallow copy of the addressable v.
+func (s *globalSaver) save(v reflect.Value) {
+	v = writable(v)
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	s.saved = append(s.saved, savedValue{kind: savedLocation, loc: v, value: c})
+}
+
+// walk saves what v refers to, failing for values that can not be copied.
+func (s *globalSaver) walk(v reflect.Value, path string) error {
+	if v.CanAddr() {
+		v = writable(v)
+	}
+	typ := v.Type()
+	if typ.PkgPath() == "sync" {
+		return uncopyableError(typ, path)
+	}
+	switch v.Kind() {
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		if v.IsNil() {
+			return nil
+		}
+		return uncopyableError(typ, path)
+	case reflect.Ptr:
+		ref := savedRef{v.Pointer(), typ}
+		if v.IsNil() || s.seen[ref] {
+			return nil
+		}
+		s.seen[ref] = true
+		s.save(v.Elem())
+		return s.walk(v.Elem(), path)
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return s.walk(v.Elem(), path)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			if !v.CanAddr() {
+				// fields of unaddressable structs are read only, what they refer to is not
+				tmp := reflect.New(typ).Elem()
+				tmp.Set(v)
+				field = tmp.Field(i)
+			}
+			if err := s.walk(field, path+"."+fieldName(typ.Field(i))); err != nil {
+				return err
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := s.walk(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		ref := savedRef{v.Pointer(), typ}
+		if v.IsNil() || v.Len() == 0 || s.seen[ref] {
+			return nil
+		}
+		s.seen[ref] = true
+		elems := reflect.MakeSlice(typ, v.Len(), v.Len())
+		reflect.Copy(elems, v)
+		s.saved = append(s.saved, savedValue{kind: savedElems, loc: copyValue(v, typ), value: elems})
+		for i := 0; i < v.Len(); i++ {
+			if err := s.walk(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		ref := savedRef{v.Pointer(), typ}
+		if v.IsNil() || s.seen[ref] {
+			return nil
+		}
+		s.seen[ref] = true
+		entries := reflect.MakeMapWithSize(typ, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			entries.SetMapIndex(iter.Key(), iter.Value())
+			if err := s.walk(iter.Value(), path+"["+keyPath(iter.Key())+"]"); err != nil {
+				return err
+			}
+		}
+		s.saved = append(s.saved, savedValue{kind: savedEntries, loc: copyValue(v, typ), value: entries})
+	}
+	return nil
+}
+
+func uncopyableError(typ reflect.Type, path string) error {
+	if path == "" {
+		return fmt.Errorf("%s can not be copied", typ)
+	}
+	return fmt.Errorf("%s at %s can not be copied", typ, path)
+}
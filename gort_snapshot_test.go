@@ -0,0 +1,73 @@
+package gort_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/lsg2020/gort/testdata/snapshot"
+	"github.com/lsg2020/gort/testdata/snapshot/dep"
+)
+
+const snapshotPkg = "github.com/lsg2020/gort/testdata/snapshot"
+
+func TestSnapshot(t *testing.T) {
+	rt := newRT(t)
+	snapshot.Mu.Lock()
+	snapshot.Mu.Unlock()
+	snapshot.Hook()
+	if snapshot.Depth() != 1 || snapshot.Name() != "snapshot" || snapshot.Notify == nil {
+		t.Fatalf("fixtures changed")
+	}
+	item := snapshot.Items[0]
+
+	snap, err := rt.Snapshot(snapshotPkg + "/...")
+	if err != nil {
+		t.Fatalf("snapshot err %s", err)
+	}
+	var skipped []string
+	for _, s := range snap.Skipped {
+		if s.Err == nil {
+			t.Errorf("skipped %s without a reason", s.Name)
+		}
+		skipped = append(skipped, s.Name)
+	}
+	sort.Strings(skipped)
+	if want := []string{snapshotPkg + ".Hook", snapshotPkg + ".Mu", snapshotPkg + ".Notify"}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("skipped %v, want %v", skipped, want)
+	}
+	saved := make(map[string]bool)
+	for _, name := range snap.Globals {
+		saved[name] = true
+	}
+	for _, name := range []string{snapshotPkg + ".Limit", snapshotPkg + ".name", snapshotPkg + "/dep.Depth"} {
+		if !saved[name] {
+			t.Errorf("global %s not in %v", name, snap.Globals)
+		}
+	}
+
+	snapshot.Limit = 20
+	snapshot.SetName("changed")
+	dep.Depth = 2
+	item.Name = "changed"
+	item.Tags[0] = "changed"
+	snapshot.Items = append(snapshot.Items, &snapshot.Item{Name: "b"})
+	snapshot.ByName["b"] = snapshot.Items[1]
+	snapshot.Current = nil
+
+	snap.Restore()
+	if snapshot.Limit != 10 || snapshot.Name() != "snapshot" || dep.Depth != 1 {
+		t.Errorf("restored limit %d name %q depth %d", snapshot.Limit, snapshot.Name(), dep.Depth)
+	}
+	// values are restored in place, pointers keep their identity
+	if len(snapshot.Items) != 1 || snapshot.Items[0] != item || snapshot.Current != item || item.Name != "a" || item.Tags[0] != "x" {
+		t.Errorf("restored items %+v current %p, want %p", snapshot.Items, snapshot.Current, item)
+	}
+	if len(snapshot.ByName) != 1 || snapshot.ByName["a"] != item {
+		t.Errorf("restored map %v", snapshot.ByName)
+	}
+
+	if _, err := rt.Snapshot(); err == nil {
+		t.Errorf("snapshot without packages succeeded")
+	}
+}
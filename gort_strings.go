@@ -0,0 +1,258 @@
+package gort
+
+import (
+	"debug/dwarf"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-delve/delve/pkg/proc"
+)
+
+// namedConst is an integer constant declared with a named type, e.g. an enum value.
+type namedConst struct {
+	name  string // qualified name, e.g. "main.StateIdle"
+	value int64
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// CallFuncStrings calls the function name with arguments parsed from text
+// into the parameter types and returns the results formatted as text.
+// Integers, floats and bools use Go syntax, strings may be quoted,
+// time.Duration values are parsed by time.ParseDuration, integers of named
+// types also accept the name of one of their constants, other types are
+// decoded from JSON and coerced as by Coerce. Types defined from
+// time.Duration are plain integers, the binary does not record their origin. Strings are quoted in the results, integers of named
+// types are formatted as the name of their constant and composite values as JSON.
+func (d *DwarfRT) CallFuncStrings(name string, args []string) ([]string, error) {
+	sig, err := d.Signature(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.rlock(); err != nil {
+		return nil, err
+	}
+	// functions and closures do not record whether they are variadic, more or
+	// fewer arguments than parameters only fit a variadic one
+	variadic := sig.Variadic || !sig.VariadicKnown && len(args) != len(sig.In)
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var param FuncParam
+		switch {
+		case variadic && i >= len(sig.In)-1:
+			last := sig.In[len(sig.In)-1]
+			param = FuncParam{Name: last.Name, TypeName: strings.TrimPrefix(last.TypeName, "[]"), Type: last.Type.Elem()}
+		case i < len(sig.In):
+			param = sig.In[i]
+		default:
+			// too many arguments, CallFunc reports it
+			in[i] = reflect.ValueOf(arg)
+			continue
+		}
+		if in[i], err = d.parseArg(arg, param); err != nil {
+			d.mu.RUnlock()
+			return nil, &CallError{Func: name, Arg: i, Err: err}
+		}
+	}
+	d.mu.RUnlock()
+
+	rets, err := d.CallFunc(name, variadic, in)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.rlock(); err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+	out := make([]string, len(rets))
+	for i, ret := range rets {
+		typeName := ""
+		if i < len(sig.Out) {
+			typeName = sig.Out[i].TypeName
+		}
+		out[i] = d.formatResult(ret, typeName)
+	}
+	return out, nil
+}
+
+// parseArg parses the text s into the type of param. d.mu must be held.
+func (d *DwarfRT) parseArg(s string, param FuncParam) (reflect.Value, error) {
+	typ := param.Type
+	kind := typ.Kind()
+	switch {
+	case kind == reflect.String:
+		if unquoted, err := strconv.Unquote(s); err == nil {
+			s = unquoted
+		}
+		return reflect.ValueOf(s).Convert(typ), nil
+	case (isInt(kind) || isUint(kind)) && typ != durationType:
+		if c, ok := d.lookupConst(param.TypeName, strings.TrimSpace(s)); ok {
+			v := reflect.New(typ).Elem()
+			if isInt(kind) {
+				v.SetInt(c.value)
+			} else {
+				v.SetUint(uint64(c.value))
+			}
+			return v, nil
+		}
+		v, err := Coerce(s, typ)
+		if err != nil && len(d.loadConsts()[param.TypeName]) > 0 {
+			return reflect.Value{}, fmt.Errorf("%q is neither a number nor a constant of %s", s, param.TypeName)
+		}
+		return v, err
+	case kind == reflect.Bool || isNumber(kind):
+		return Coerce(s, typ)
+	}
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		if kind == reflect.Interface && typ.NumMethod() == 0 {
+			// bare words for any parameters, e.g. the arguments of fmt.Sprintf
+			return reflect.ValueOf(&s).Elem(), nil
+		}
+		return reflect.Value{}, fmt.Errorf("parse %s as JSON: %w", typ, err)
+	}
+	return Coerce(v, typ)
+}
+
+// formatResult formats the result v of the DWARF type typeName. d.mu must be held.
+func (d *DwarfRT) formatResult(v reflect.Value, typeName string) string {
+	if !v.IsValid() {
+		return "nil"
+	}
+	typ := v.Type()
+	kind := typ.Kind()
+	switch {
+	case kind == reflect.Interface:
+		if v.IsNil() {
+			return "nil"
+		}
+		if typ.Implements(errorType) {
+			return strconv.Quote(v.Interface().(error).Error())
+		}
+		return d.formatResult(v.Elem(), "")
+	case kind == reflect.String:
+		return strconv.Quote(v.String())
+	case kind == reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case typ == durationType:
+		return v.Interface().(fmt.Stringer).String()
+	case isInt(kind):
+		if c, ok := d.constByValue(typeName, v.Int()); ok {
+			return c.name
+		}
+		return strconv.FormatInt(v.Int(), 10)
+	case isUint(kind):
+		if c, ok := d.constByValue(typeName, int64(v.Uint())); ok {
+			return c.name
+		}
+		return strconv.FormatUint(v.Uint(), 10)
+	case isFloat(kind):
+		return strconv.FormatFloat(v.Float(), 'g', -1, typ.Bits())
+	case isComplex(kind):
+		return strconv.FormatComplex(v.Complex(), 'g', -1, typ.Bits())
+	case kind == reflect.Func || kind == reflect.Chan || kind == reflect.UnsafePointer:
+		return fmt.Sprintf("%s(%#x)", typ, v.Pointer())
+	}
+	if b, err := json.Marshal(v.Interface()); err == nil {
+		return string(b)
+	}
+	return fmt.Sprintf("%+v", v.Interface())
+}
+
+// lookupConst returns the constant of the type typeName named name, either
+// qualified or local to the package of the type. d.mu must be held.
+func (d *DwarfRT) lookupConst(typeName, name string) (namedConst, bool) {
+	for _, c := range d.loadConsts()[typeName] {
+		if c.name == name {
+			return c, true
+		}
+		if _, local := splitPackage(c.name); local == name {
+			return c, true
+		}
+	}
+	return namedConst{}, false
+}
+
+// constByValue returns the first constant of the type typeName with the value value. d.mu must be held.
+func (d *DwarfRT) constByValue(typeName string, value int64) (namedConst, bool) {
+	if typeName == "" {
+		return namedConst{}, false
+	}
+	for _, c := range d.loadConsts()[typeName] {
+		if c.value == value {
+			return c, true
+		}
+	}
+	return namedConst{}, false
+}
+
+// loadConsts groups the integer constants loaded by delve by the name of their type,
+// in declaration order. The constants of the executable come first.
+func (d *DwarfRT) loadConsts() map[string][]namedConst {
+	snap := d.snap
+	snap.constsOnce.Do(func() {
+		snap.consts = make(map[string][]namedConst)
+		consts := reflect.ValueOf(d.bi).Elem().FieldByName("consts")
+		if !consts.IsValid() {
+			return
+		}
+		type constType struct {
+			img    int
+			offset uint64
+			values reflect.Value
+		}
+		var types []constType
+		iter := consts.MapRange()
+		for iter.Next() {
+			key, ct := iter.Key(), iter.Value()
+			rIndex, rOffset := key.FieldByName("imageIndex"), key.FieldByName("offset")
+			if !rIndex.IsValid() || !rOffset.IsValid() || ct.IsNil() {
+				continue
+			}
+			values := ct.Elem().FieldByName("values")
+			if !values.IsValid() || int(rIndex.Int()) >= len(d.bi.Images) {
+				continue
+			}
+			types = append(types, constType{img: int(rIndex.Int()), offset: rOffset.Uint(), values: values})
+		}
+		sort.Slice(types, func(i, j int) bool {
+			if types[i].img != types[j].img {
+				return types[i].img < types[j].img
+			}
+			return types[i].offset < types[j].offset
+		})
+		for _, t := range types {
+			name := constTypeName(d.bi.Images[t.img], t.offset)
+			if name == "" {
+				continue
+			}
+			for i := 0; i < t.values.Len(); i++ {
+				rv := t.values.Index(i)
+				rName, rValue := rv.FieldByName("name"), rv.FieldByName("value")
+				if !rName.IsValid() || !rValue.IsValid() {
+					continue
+				}
+				snap.consts[name] = append(snap.consts[name], namedConst{name: rName.String(), value: rValue.Int()})
+			}
+		}
+	})
+	return snap.consts
+}
+
+// constTypeName returns the name of the DWARF type at offset in img.
+func constTypeName(img *proc.Image, offset uint64) string {
+	reader := img.DwarfReader()
+	reader.Seek(dwarf.Offset(offset))
+	entry, err := reader.Next()
+	if err != nil || entry == nil {
+		return ""
+	}
+	name, _ := entry.Val(dwarf.AttrName).(string)
+	return name
+}
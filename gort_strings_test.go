@@ -0,0 +1,100 @@
+package gort_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lsg2020/gort"
+)
+
+type strLevel int
+
+const (
+	strLevelLow strLevel = iota
+	strLevelMid
+	strLevelHigh
+)
+
+type strTicks int64
+
+type strConfig struct {
+	Name  string
+	burst int
+}
+
+var errStrNegative = errors.New("negative")
+
+//go:noinline
+func strSetLimit(limit int, tenant string, window time.Duration, lvl strLevel, cfg *strConfig, ok bool, ratio float64) (string, strLevel, error) {
+	if limit < 0 {
+		return "", 0, errStrNegative
+	}
+	return fmt.Sprintf("%d %s %v %d %+v %v %v", limit, tenant, window, lvl, *cfg, ok, ratio), lvl + 1, nil
+}
+
+//go:noinline
+func strWait(n strTicks) strTicks {
+	return n + 1
+}
+
+//go:noinline
+func strConf(n int) strConfig {
+	return strConfig{Name: "c", burst: n}
+}
+
+func TestCallFuncStrings(t *testing.T) {
+	rt := newRT(t)
+	strSetLimit(0, "", 0, strLevelLow, &strConfig{}, false, 0)
+	strWait(0)
+	strConf(0)
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			testPkg + ".strSetLimit",
+			[]string{"42", `"tenant-a"`, "5s", "strLevelMid", `{"Name": "x", "burst": 3}`, "true", "0.5"},
+			[]string{`"42 tenant-a 5s 1 {Name:x burst:3} true 0.5"`, testPkg + ".strLevelHigh", "nil"},
+		},
+		{
+			testPkg + ".strSetLimit",
+			[]string{"-1", "t", "1m", "2", "{}", "false", "1"},
+			[]string{`""`, testPkg + ".strLevelLow", `"negative"`},
+		},
+		{testPkg + ".strWait", []string{"7"}, []string{"8"}},
+		{testPkg + ".strConf", []string{"0x10"}, []string{`{"Name":"c"}`}},
+		// any parameters take bare words and JSON
+		{"fmt.Sprintf", []string{`"%v-%v"`, "hello", "[1,2]"}, []string{`"hello-[1 2]"`}},
+	}
+	for _, test := range tests {
+		rets, err := rt.CallFuncStrings(test.name, test.args)
+		if err != nil {
+			t.Errorf("call %s %v err %s", test.name, test.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(rets, test.want) {
+			t.Errorf("call %s %v = %q, want %q", test.name, test.args, rets, test.want)
+		}
+	}
+
+	var callErr *gort.CallError
+	for _, test := range []struct {
+		name string
+		args []string
+		arg  int
+	}{
+		{testPkg + ".strSetLimit", []string{"x", "t", "1m", "2", "null", "false", "1"}, 0},
+		{testPkg + ".strSetLimit", []string{"1", "t", "1m", "strLevelNone", "null", "false", "1"}, 3},
+		// only time.Duration parses durations, types defined from it are integers
+		{testPkg + ".strWait", []string{"2m"}, 0},
+	} {
+		if _, err := rt.CallFuncStrings(test.name, test.args); !errors.As(err, &callErr) || callErr.Arg != test.arg {
+			t.Errorf("call %s %v err %v, want an error for argument %d", test.name, test.args, err, test.arg)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package gort
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/go-delve/delve/pkg/dwarf/godwarf"
+)
+
+// IsSynthesized reports whether typ, or a type it is composed of, was built
+// from DWARF because the binary has no runtime type descriptor for it.
+// Synthesized types share the memory layout of the original type but not its
+// name or methods, unexported fields get exported names prefixed with "X".
+func (d *DwarfRT) IsSynthesized(typ reflect.Type) bool {
+	if typ == nil {
+		return false
+	}
+	if _, ok := d.synthesized.Load(typ); ok {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Chan:
+		return d.IsSynthesized(typ.Elem())
+	case reflect.Map:
+		return d.IsSynthesized(typ.Key()) || d.IsSynthesized(typ.Elem())
+	case reflect.Func:
+		for i := 0; i < typ.NumIn(); i++ {
+			if d.IsSynthesized(typ.In(i)) {
+				return true
+			}
+		}
+		for i := 0; i < typ.NumOut(); i++ {
+			if d.IsSynthesized(typ.Out(i)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// synthesizeType builds a reflect.Type with the same layout as dtyp.
+// Named parts that have a runtime type keep it, visiting tracks the structs
+// being built to break reference cycles. d.dwarfMu must be held.
+func (d *DwarfRT) synthesizeType(dtyp godwarf.Type, visiting map[dwarf.Offset]bool) (reflect.Type, error) {
+	common := dtyp.Common()
+	if _, isStruct := dtyp.(*godwarf.StructType); isStruct {
+		if visiting[common.Offset] {
+			return nil, errTypeCycle
+		}
+		visiting[common.Offset] = true
+		defer delete(visiting, common.Offset)
+	}
+
+	typ, err := d.synthesizeTypeIntl(dtyp, visiting)
+	if err != nil {
+		return nil, err
+	}
+	if uintptr(common.ByteSize) != typ.Size() && common.ByteSize > 0 {
+		return nil, fmt.Errorf("synthesized type %s size %d, dwarf size %d", common.Name, typ.Size(), common.ByteSize)
+	}
+	if typ.Kind() == reflect.Struct && typ.Name() == "" {
+		d.synthesized.LoadOrStore(typ, common.Name)
+	}
+	return typ, nil
+}
+
+var errTypeCycle = fmt.Errorf("type cycle")
+
+// checkUnderlying fails when the named type name resolved to typ, a type
+// without name such as its underlying type. Only synthesized structs are
+// recorded, the underlying type of other named types is shared with other
+// types and IsSynthesized would not report it.
+func checkUnderlying(name string, typ reflect.Type) error {
+	if typ.Kind() == reflect.Struct || typ.PkgPath() != "" {
+		return nil
+	}
+	if _, ok := builtinTypes[name]; ok {
+		return nil
+	}
+	if expr, err := parseTypeExpr(name); err != nil || expr.kind != typeExprNamed {
+		return nil
+	}
+	return fmt.Errorf("runtime type of %s %w, only its underlying type %s can be built from DWARF", name, ErrNotFound, typ)
+}
+
+// synthesizeElem resolves a type referenced by another one, preferring its runtime type.
+func (d *DwarfRT) synthesizeElem(dtyp godwarf.Type, visiting map[dwarf.Offset]bool) (reflect.Type, error) {
+	if name := dtyp.Common().Name; name != "" {
+		if typeAddr, err := d.dwarfToRuntimeType(dtyp, name); err == nil {
+			return runtimeTypeAt(typeAddr), nil
+		}
+	}
+	return d.synthesizeType(dtyp, visiting)
+}
+
+func (d *DwarfRT) synthesizeTypeIntl(dtyp godwarf.Type, visiting map[dwarf.Offset]bool) (reflect.Type, error) {
+	common := dtyp.Common()
+	if builtin, ok := builtinTypes[common.Name]; ok && common.Name != "any" {
+		return builtin, nil
+	}
+
+	switch dtyp := dtyp.(type) {
+	case *godwarf.TypedefType:
+		return d.synthesizeElem(dtyp.Type, visiting)
+
+	case *godwarf.ParametricType:
+		return d.synthesizeElem(dtyp.Type, visiting)
+
+	case *godwarf.PtrType:
+		elem, err := d.synthesizeElem(dtyp.Type, visiting)
+		if err == errTypeCycle {
+			return builtinTypes["unsafe.Pointer"], nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return reflect.PtrTo(elem), nil
+
+	case *godwarf.StringType:
+		return builtinTypes["string"], nil
+
+	case *godwarf.SliceType:
+		elem, err := d.synthesizeElem(dtyp.ElemType, visiting)
+		if err == errTypeCycle {
+			// []T inside T, keep the slice header without an element type
+			return reflect.StructOf([]reflect.StructField{
+				{Name: "Data", Type: builtinTypes["unsafe.Pointer"]},
+				{Name: "Len", Type: builtinTypes["int"]},
+				{Name: "Cap", Type: builtinTypes["int"]},
+			}), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return reflect.SliceOf(elem), nil
+
+	case *godwarf.ArrayType:
+		elem, err := d.synthesizeElem(dtyp.Type, visiting)
+		if err != nil {
+			return nil, err
+		}
+		if dtyp.Count < 0 {
+			return nil, fmt.Errorf("incomplete array type %s", common.Name)
+		}
+		return reflect.ArrayOf(int(dtyp.Count), elem), nil
+
+	case *godwarf.MapType:
+		key, err := d.synthesizeElem(dtyp.KeyType, visiting)
+		if err != nil {
+			return nil, err
+		}
+		elem, err := d.synthesizeElem(dtyp.ElemType, visiting)
+		if err == errTypeCycle || (err == nil && !key.Comparable()) {
+			// the map header is a single pointer
+			return builtinTypes["unsafe.Pointer"], nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return reflect.MapOf(key, elem), nil
+
+	case *godwarf.ChanType:
+		elem, err := d.synthesizeElem(dtyp.ElemType, visiting)
+		if err == errTypeCycle || (err == nil && elem.Size() >= 1<<16) {
+			return builtinTypes["unsafe.Pointer"], nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return reflect.ChanOf(reflect.BothDir, elem), nil
+
+	case *godwarf.InterfaceType:
+		if st, ok := dtyp.Type.(*godwarf.StructType); ok && st.StructName == "runtime.eface" {
+			return builtinTypes["any"], nil
+		}
+		// non-empty interfaces are an itab and a data pointer
+		return reflect.StructOf([]reflect.StructField{
+			{Name: "Tab", Type: builtinTypes["unsafe.Pointer"]},
+			{Name: "Data", Type: builtinTypes["unsafe.Pointer"]},
+		}), nil
+
+	case *godwarf.FuncType:
+		// func values are a pointer to a closure
+		return builtinTypes["unsafe.Pointer"], nil
+
+	case *godwarf.StructType:
+		return d.synthesizeStruct(dtyp, visiting)
+
+	case *godwarf.BoolType:
+		return builtinTypes["bool"], nil
+	case *godwarf.IntType:
+		return sizedBasicType(common, "int")
+	case *godwarf.UintType:
+		if common.ReflectKind == reflect.Uintptr {
+			return builtinTypes["uintptr"], nil
+		}
+		return sizedBasicType(common, "uint")
+	case *godwarf.CharType:
+		return sizedBasicType(common, "int")
+	case *godwarf.UcharType:
+		return sizedBasicType(common, "uint")
+	case *godwarf.FloatType:
+		return sizedBasicType(common, "float")
+	case *godwarf.ComplexType:
+		return sizedBasicType(common, "complex")
+	}
+
+	if common.ReflectKind == reflect.UnsafePointer || common.ReflectKind == reflect.Func {
+		return builtinTypes["unsafe.Pointer"], nil
+	}
+	return nil, fmt.Errorf("can not synthesize type %s:%T", common.Name, dtyp)
+}
+
+func sizedBasicType(common *godwarf.CommonType, prefix string) (reflect.Type, error) {
+	name := fmt.Sprintf("%s%d", prefix, common.ByteSize*8)
+	typ, ok := builtinTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("can not synthesize basic type %s size %d", common.Name, common.ByteSize)
+	}
+	return typ, nil
+}
+
+func (d *DwarfRT) synthesizeStruct(dtyp *godwarf.StructType, visiting map[dwarf.Offset]bool) (reflect.Type, error) {
+	if dtyp.Incomplete || dtyp.Kind != "struct" {
+		return nil, fmt.Errorf("can not synthesize %s %s", dtyp.Kind, dtyp.StructName)
+	}
+
+	used := make(map[string]bool)
+	fields := make([]reflect.StructField, 0, len(dtyp.Field))
+	for i, field := range dtyp.Field {
+		if field.BitSize != 0 {
+			return nil, fmt.Errorf("can not synthesize bit field %s.%s", dtyp.StructName, field.Name)
+		}
+		ftyp, err := d.synthesizeElem(field.Type, visiting)
+		if err != nil {
+			return nil, err
+		}
+
+		name := synthesizedFieldName(field.Name, i)
+		for used[name] {
+			name += "_"
+		}
+		used[name] = true
+		fields = append(fields, reflect.StructField{Name: name, Type: ftyp, Tag: reflect.StructTag(fmt.Sprintf("gort:%q", field.Name))})
+	}
+
+	typ := reflect.StructOf(fields)
+	for i, field := range dtyp.Field {
+		if typ.Field(i).Offset != uintptr(field.ByteOffset) {
+			return nil, fmt.Errorf("synthesized struct %s field %s offset %d, dwarf offset %d",
+				dtyp.StructName, field.Name, typ.Field(i).Offset, field.ByteOffset)
+		}
+	}
+	return typ, nil
+}
+
+// fieldName returns the DWARF name of field, synthesized structs keep it in the gort tag.
+func fieldName(field reflect.StructField) string {
+	if name, ok := field.Tag.Lookup("gort"); ok {
+		return name
+	}
+	return field.Name
+}
+
+// synthesizedFieldName maps a DWARF field name to an exported identifier,
+// reflect.StructOf does not allow unexported fields.
+func synthesizedFieldName(name string, index int) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+	if name == "" || name == "_" {
+		return fmt.Sprintf("X_%d", index)
+	}
+	first := []rune(name)[0]
+	if !unicode.IsUpper(first) {
+		return "X" + name
+	}
+	return name
+}
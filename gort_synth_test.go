@@ -0,0 +1,104 @@
+package gort_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"unsafe"
+
+	"github.com/lsg2020/gort"
+)
+
+// the types below are never converted to an interface, the binary has no
+// runtime type for them
+
+type synthInner struct {
+	a int8
+	b int64
+	s []string
+}
+
+type synthNode struct {
+	next *synthNode
+	val  synthInner
+	arr  [3]uint16
+	flag bool
+}
+
+type synthID int
+
+var (
+	synthGlobal = synthNode{val: synthInner{a: 1, b: 2, s: []string{"x"}}, arr: [3]uint16{1, 2, 3}, flag: true}
+	synthIDs    = []synthID{3}
+)
+
+//go:noinline
+func synthSum(n synthNode, k int) int {
+	return int(n.val.a) + int(n.val.b) + int(n.arr[2]) + k
+}
+
+func TestSynthesizedType(t *testing.T) {
+	rt := newRT(t)
+	if synthSum(synthGlobal, 1) != 7 || synthIDs[0] != 3 {
+		t.Fatalf("fixtures changed")
+	}
+
+	typ, err := rt.FindType(testPkg + ".synthNode")
+	if err != nil {
+		t.Fatalf("find type err %s", err)
+	}
+	if !rt.IsSynthesized(typ) {
+		t.Errorf("type %s has a runtime type", typ)
+	}
+	if typ.Size() != unsafe.Sizeof(synthNode{}) || typ.NumField() != 4 {
+		t.Errorf("synthesized type %s has size %d and %d fields", typ, typ.Size(), typ.NumField())
+	}
+	// reflect can not build recursive types, the pointer to itself is left untyped
+	if next := typ.Field(0).Type; next.Kind() != reflect.UnsafePointer {
+		t.Errorf("field next has type %s", next)
+	}
+
+	v, err := rt.FindGlobal(testPkg + ".synthGlobal")
+	if err != nil {
+		t.Fatalf("find global err %s", err)
+	}
+	if v.Type() != typ {
+		t.Errorf("global has type %s, want %s", v.Type(), typ)
+	}
+	if name := typ.Field(1).Tag.Get("gort"); name != "val" {
+		t.Errorf("field val has the DWARF name %q", name)
+	}
+	b, err := gort.FieldByPath(v, "val.b")
+	if err != nil {
+		t.Fatalf("field by DWARF name err %s", err)
+	}
+	if b.Int() != 2 {
+		t.Errorf("val.b is %d", b.Int())
+	}
+	if flag := v.Field(3).Bool(); !flag {
+		t.Errorf("flag is false")
+	}
+
+	rets, err := rt.CallFunc(testPkg+".synthSum", false, []reflect.Value{v, reflect.ValueOf(10)})
+	if err != nil {
+		t.Fatalf("call err %s", err)
+	}
+	if rets[0].Int() != 16 {
+		t.Errorf("synthSum returned %d", rets[0].Int())
+	}
+}
+
+func TestSynthesizedNamedType(t *testing.T) {
+	rt := newRT(t)
+	if _, err := rt.FindType(testPkg + ".synthID"); !errors.Is(err, gort.ErrNotFound) {
+		t.Errorf("find named int without runtime type err %v, want ErrNotFound", err)
+	}
+	// its underlying type is still built for composite types
+	typ, err := rt.FindType("[]" + testPkg + ".synthID")
+	if err != nil {
+		t.Fatalf("find slice type err %s", err)
+	}
+	if elem := typ.Elem(); elem.Size() != unsafe.Sizeof(synthID(0)) || elem.Kind() != reflect.Int && elem.Kind() != reflect.Int64 {
+		t.Errorf("slice type %s", typ)
+	}
+}
@@ -0,0 +1,178 @@
+package gort_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"plugin"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lsg2020/gort"
+	"github.com/lsg2020/gort/internal/testdwarf"
+)
+
+// testPkg is the package path of the tests in DWARF.
+const testPkg = "github.com/lsg2020/gort_test"
+
+func TestMain(m *testing.M) {
+	code := testdwarf.Run(m)
+	if pluginDir != "" {
+		os.RemoveAll(pluginDir)
+	}
+	os.Exit(code)
+}
+
+var (
+	rtOnce sync.Once
+	rt     *gort.DwarfRT
+	rtErr  error
+)
+
+// newRT returns the DwarfRT of the test binary shared by the tests.
+func newRT(t *testing.T) *gort.DwarfRT {
+	t.Helper()
+	rtOnce.Do(func() {
+		rt, rtErr = gort.NewDwarfRT("")
+	})
+	if rtErr != nil {
+		t.Fatalf("load dwarf err %s", rtErr)
+	}
+	return rt
+}
+
+type point struct {
+	x, y int
+}
+
+var (
+	counter = 7
+	origin  = &point{1, 2}
+)
+
+//go:noinline
+func scale(p point, n int) point {
+	return point{p.x * n, p.y * n}
+}
+
+func TestConcurrentAddImage(t *testing.T) {
+	if scale(*origin, counter) != (point{7, 14}) {
+		t.Fatalf("scale %v", scale(*origin, counter))
+	}
+	// a DwarfRT of its own, the plugin is not added to the one shared by the tests
+	rt, err := gort.NewDwarfRT("")
+	if err != nil {
+		t.Fatalf("load dwarf err %s", err)
+	}
+	plugPath, libAddr := loadPlugin(t, rt, "a")
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if v, err := rt.FindGlobal(testPkg + ".counter"); err != nil || v.Int() != 7 {
+					t.Errorf("find global %v err %v", v, err)
+					return
+				}
+				if typ, err := rt.FindType(testPkg + ".point"); err != nil || typ.Size() != 16 {
+					t.Errorf("find type %v err %v", typ, err)
+					return
+				}
+				if _, err := rt.FindFunc(testPkg+".scale", false); err != nil {
+					t.Errorf("find func err %v", err)
+					return
+				}
+				if _, err := rt.Eval(testPkg + ".origin.y"); err != nil {
+					t.Errorf("eval err %v", err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		if err := rt.AddImage(plugPath, libAddr); err != nil {
+			t.Errorf("add image err %s", err)
+			break
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	if _, err := rt.FindGlobal("a.so:" + pluginPkg + "a.Name"); err != nil {
+		t.Fatalf("find plugin global err %s", err)
+	}
+}
+
+// pluginPkg is the package path of the directory of the test plugins.
+const pluginPkg = "github.com/lsg2020/gort/testdata/plugins/"
+
+var (
+	pluginMu  sync.Mutex
+	pluginDir string
+	// plugins maps the names of the built test plugins to their paths, a
+	// plugin is loaded once per process
+	plugins = make(map[string]string)
+)
+
+// loadPlugin builds and opens the plugin testdata/plugins/name with the flags
+// of the test and returns its path and load address. The test is skipped when
+// plugins are not available.
+func loadPlugin(t *testing.T, rt *gort.DwarfRT, name string) (string, uint64) {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skipf("plugins are not supported on %s", runtime.GOOS)
+	}
+	if out, err := exec.Command("go", "env", "CGO_ENABLED").Output(); err != nil || strings.TrimSpace(string(out)) != "1" {
+		t.Skip("plugins need cgo")
+	}
+
+	pluginMu.Lock()
+	defer pluginMu.Unlock()
+	plugPath, ok := plugins[name]
+	if !ok {
+		if pluginDir == "" {
+			dir, err := os.MkdirTemp("", "gort-plugins")
+			if err != nil {
+				t.Fatalf("create plugin dir err %s", err)
+			}
+			pluginDir = dir
+		}
+		plugPath = filepath.Join(pluginDir, name+".so")
+		args := []string{"build", "-buildmode=plugin", "-o", plugPath}
+		if testdwarf.RaceEnabled {
+			args = append(args, "-race")
+		}
+		if out, err := exec.Command("go", append(args, "./testdata/plugins/"+name)...).CombinedOutput(); err != nil {
+			t.Fatalf("build plugin err %s\n%s", err, out)
+		}
+		if _, err := plugin.Open(plugPath); err != nil {
+			if strings.Contains(err.Error(), "different version of package") {
+				t.Skipf("plugin built with other flags than the test: %s", err)
+			}
+			t.Fatalf("open plugin err %s", err)
+		}
+		plugins[name] = plugPath
+	}
+
+	libs, addrs, err := rt.SearchPlugins()
+	if err != nil {
+		t.Fatalf("search plugins err %s", err)
+	}
+	for i, lib := range libs {
+		if lib == plugPath {
+			return plugPath, addrs[i]
+		}
+	}
+	t.Fatalf("plugin %s not loaded: %v", plugPath, libs)
+	return "", 0
+}
@@ -0,0 +1,471 @@
+package gort
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"unsafe"
+)
+
+type typeExprKind int
+
+const (
+	typeExprNamed typeExprKind = iota
+	typeExprPtr
+	typeExprSlice
+	typeExprArray
+	typeExprMap
+	typeExprChan
+	typeExprFunc
+	typeExprEmptyInterface
+	typeExprEmptyStruct
+)
+
+// typeExpr is a parsed Go type expression, named parts keep their full DWARF name.
+type typeExpr struct {
+	kind typeExprKind
+	name string
+
+	len      int
+	dir      reflect.ChanDir
+	key      *typeExpr
+	elem     *typeExpr
+	in, out  []*typeExpr
+	variadic bool
+}
+
+// builtinTypes are used for predeclared names without a runtime type in the binary.
+var builtinTypes = map[string]reflect.Type{
+	"bool":           reflect.TypeOf(false),
+	"int":            reflect.TypeOf(int(0)),
+	"int8":           reflect.TypeOf(int8(0)),
+	"int16":          reflect.TypeOf(int16(0)),
+	"int32":          reflect.TypeOf(int32(0)),
+	"int64":          reflect.TypeOf(int64(0)),
+	"uint":           reflect.TypeOf(uint(0)),
+	"uint8":          reflect.TypeOf(uint8(0)),
+	"uint16":         reflect.TypeOf(uint16(0)),
+	"uint32":         reflect.TypeOf(uint32(0)),
+	"uint64":         reflect.TypeOf(uint64(0)),
+	"uintptr":        reflect.TypeOf(uintptr(0)),
+	"float32":        reflect.TypeOf(float32(0)),
+	"float64":        reflect.TypeOf(float64(0)),
+	"complex64":      reflect.TypeOf(complex64(0)),
+	"complex128":     reflect.TypeOf(complex128(0)),
+	"string":         reflect.TypeOf(""),
+	"byte":           reflect.TypeOf(byte(0)),
+	"rune":           reflect.TypeOf(rune(0)),
+	"error":          reflect.TypeOf((*error)(nil)).Elem(),
+	"any":            reflect.TypeOf((*interface{})(nil)).Elem(),
+	"unsafe.Pointer": reflect.TypeOf(unsafe.Pointer(nil)),
+}
+
+// parseTypeExpr parses Go type expression syntax as used in DWARF type names,
+// e.g. "[]main.T", "map[string]*github.com/x/y.T" or "func(int, ...string) (bool, error)".
+func parseTypeExpr(s string) (*typeExpr, error) {
+	p := &typeParser{s: s}
+	expr, err := p.parseType()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return nil, p.errorf("unexpected %q", p.s[p.pos:])
+	}
+	return expr, nil
+}
+
+type typeParser struct {
+	s   string
+	pos int
+}
+
+func (p *typeParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("parse type %q at %d: %s", p.s, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *typeParser) skipSpace() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *typeParser) peek(prefix string) bool {
+	return strings.HasPrefix(p.s[p.pos:], prefix)
+}
+
+// keyword reports whether kw follows as a whole word and consumes it.
+func (p *typeParser) keyword(kw string) bool {
+	if !p.peek(kw) {
+		return false
+	}
+	next := p.pos + len(kw)
+	if next < len(p.s) && isNameByte(p.s[next]) {
+		return false
+	}
+	p.pos = next
+	return true
+}
+
+func (p *typeParser) expect(tok string) error {
+	p.skipSpace()
+	if !p.peek(tok) {
+		return p.errorf("expected %q", tok)
+	}
+	p.pos += len(tok)
+	return nil
+}
+
+func (p *typeParser) parseType() (*typeExpr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return nil, p.errorf("missing type")
+	}
+
+	switch {
+	case p.peek("*"):
+		p.pos++
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		return &typeExpr{kind: typeExprPtr, elem: elem}, nil
+
+	case p.peek("("):
+		p.pos++
+		expr, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+
+	case p.peek("["):
+		p.pos++
+		p.skipSpace()
+		expr := &typeExpr{kind: typeExprSlice}
+		if !p.peek("]") {
+			start := p.pos
+			for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+				p.pos++
+			}
+			n, err := strconv.Atoi(p.s[start:p.pos])
+			if err != nil {
+				return nil, p.errorf("bad array length")
+			}
+			expr.kind = typeExprArray
+			expr.len = n
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		expr.elem = elem
+		return expr, nil
+
+	case p.peek("<-"):
+		p.pos += 2
+		p.skipSpace()
+		if !p.keyword("chan") {
+			return nil, p.errorf("expected chan")
+		}
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		return &typeExpr{kind: typeExprChan, dir: reflect.RecvDir, elem: elem}, nil
+
+	case p.keyword("chan"):
+		dir := reflect.BothDir
+		if p.peek("<-") {
+			p.pos += 2
+			dir = reflect.SendDir
+		}
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		return &typeExpr{kind: typeExprChan, dir: dir, elem: elem}, nil
+
+	case p.peek("map["):
+		p.pos += len("map[")
+		key, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		return &typeExpr{kind: typeExprMap, key: key, elem: elem}, nil
+
+	case p.peek("func("):
+		p.pos += len("func")
+		return p.parseFunc()
+
+	case p.keyword("interface"):
+		p.skipSpace()
+		if err := p.expect("{"); err != nil {
+			return nil, err
+		}
+		if err := p.expect("}"); err != nil {
+			return nil, ErrNotSupport
+		}
+		return &typeExpr{kind: typeExprEmptyInterface}, nil
+
+	case p.keyword("struct"):
+		p.skipSpace()
+		if err := p.expect("{"); err != nil {
+			return nil, err
+		}
+		if err := p.expect("}"); err != nil {
+			return nil, ErrNotSupport
+		}
+		return &typeExpr{kind: typeExprEmptyStruct}, nil
+	}
+
+	return p.parseNamed()
+}
+
+func (p *typeParser) parseFunc() (*typeExpr, error) {
+	expr := &typeExpr{kind: typeExprFunc}
+	in, variadic, err := p.parseParams(true)
+	if err != nil {
+		return nil, err
+	}
+	expr.in = in
+	expr.variadic = variadic
+
+	p.skipSpace()
+	switch {
+	case p.peek("("):
+		if expr.out, _, err = p.parseParams(false); err != nil {
+			return nil, err
+		}
+	case p.pos < len(p.s) && !strings.ContainsRune(",)]", rune(p.s[p.pos])):
+		out, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		expr.out = []*typeExpr{out}
+	}
+	return expr, nil
+}
+
+func (p *typeParser) parseParams(allowVariadic bool) ([]*typeExpr, bool, error) {
+	if err := p.expect("("); err != nil {
+		return nil, false, err
+	}
+	var params []*typeExpr
+	variadic := false
+	for {
+		p.skipSpace()
+		if p.peek(")") {
+			p.pos++
+			return params, variadic, nil
+		}
+		if variadic {
+			return nil, false, p.errorf("variadic parameter must be last")
+		}
+		if len(params) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, false, err
+			}
+			p.skipSpace()
+		}
+		if allowVariadic && p.peek("...") {
+			p.pos += len("...")
+			variadic = true
+			elem, err := p.parseType()
+			if err != nil {
+				return nil, false, err
+			}
+			params = append(params, &typeExpr{kind: typeExprSlice, elem: elem})
+			continue
+		}
+		param, err := p.parseType()
+		if err != nil {
+			return nil, false, err
+		}
+		params = append(params, param)
+	}
+}
+
+// parseNamed reads a qualified name such as "github.com/x/y.T",
+// type arguments of generic instantiations stay part of the name.
+func (p *typeParser) parseNamed() (*typeExpr, error) {
+	start := p.pos
+	for p.pos < len(p.s) {
+		r, size := utf8.DecodeRuneInString(p.s[p.pos:])
+		if r < utf8.RuneSelf && !isNameByte(byte(r)) {
+			break
+		}
+		if r >= utf8.RuneSelf && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		p.pos += size
+	}
+	if p.pos == start {
+		return nil, p.errorf("unexpected %q", p.s[p.pos:])
+	}
+	if p.peek("[") {
+		depth := 0
+		for ; p.pos < len(p.s); p.pos++ {
+			if p.s[p.pos] == '[' {
+				depth++
+			} else if p.s[p.pos] == ']' {
+				depth--
+				if depth == 0 {
+					p.pos++
+					break
+				}
+			}
+		}
+		if depth != 0 {
+			return nil, p.errorf("unbalanced type arguments")
+		}
+	}
+	return &typeExpr{kind: typeExprNamed, name: p.s[start:p.pos]}, nil
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c == '.' || c == '/' || c == '-' || c == '%' || c == '~' ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// String formats expr the way DWARF type names are spelled.
+func (e *typeExpr) String() string {
+	switch e.kind {
+	case typeExprNamed:
+		return normalizeInstantiations(e.name)
+	case typeExprPtr:
+		return "*" + e.elem.String()
+	case typeExprSlice:
+		return "[]" + e.elem.String()
+	case typeExprArray:
+		return "[" + strconv.Itoa(e.len) + "]" + e.elem.String()
+	case typeExprMap:
+		return "map[" + e.key.String() + "]" + e.elem.String()
+	case typeExprChan:
+		switch e.dir {
+		case reflect.RecvDir:
+			return "<-chan " + e.elem.String()
+		case reflect.SendDir:
+			return "chan<- " + e.elem.String()
+		}
+		return "chan " + e.elem.String()
+	case typeExprFunc:
+		in := make([]string, len(e.in))
+		for i, param := range e.in {
+			in[i] = param.String()
+			if e.variadic && i == len(e.in)-1 {
+				in[i] = "..." + param.elem.String()
+			}
+		}
+		s := "func(" + strings.Join(in, ", ") + ")"
+		switch len(e.out) {
+		case 0:
+		case 1:
+			s += " " + e.out[0].String()
+		default:
+			out := make([]string, len(e.out))
+			for i, param := range e.out {
+				out[i] = param.String()
+			}
+			s += " (" + strings.Join(out, ", ") + ")"
+		}
+		return s
+	case typeExprEmptyInterface:
+		return "interface {}"
+	case typeExprEmptyStruct:
+		return "struct {}"
+	}
+	return e.name
+}
+
+// buildType resolves the named parts of expr and composes them with reflect.
+// d.dwarfMu must be held.
+func (d *DwarfRT) buildType(expr *typeExpr) (reflect.Type, error) {
+	return buildTypeExpr(expr, d.resolveTypeName)
+}
+
+// buildTypeExpr composes expr with reflect, resolving its named parts with resolve.
+func buildTypeExpr(expr *typeExpr, resolve func(name string) (reflect.Type, error)) (reflect.Type, error) {
+	switch expr.kind {
+	case typeExprNamed:
+		return resolve(expr.name)
+
+	case typeExprEmptyInterface:
+		return builtinTypes["any"], nil
+
+	case typeExprEmptyStruct:
+		return reflect.TypeOf(struct{}{}), nil
+
+	case typeExprPtr, typeExprSlice, typeExprArray, typeExprChan:
+		elem, err := buildTypeExpr(expr.elem, resolve)
+		if err != nil {
+			return nil, err
+		}
+		switch expr.kind {
+		case typeExprPtr:
+			return reflect.PtrTo(elem), nil
+		case typeExprSlice:
+			return reflect.SliceOf(elem), nil
+		case typeExprArray:
+			if elem.Size() != 0 && uintptr(expr.len) > ^uintptr(0)/elem.Size() {
+				return nil, fmt.Errorf("array too large [%d]%s", expr.len, elem)
+			}
+			return reflect.ArrayOf(expr.len, elem), nil
+		default:
+			if elem.Size() >= 1<<16 {
+				return nil, fmt.Errorf("channel element type too large %s", elem)
+			}
+			return reflect.ChanOf(expr.dir, elem), nil
+		}
+
+	case typeExprMap:
+		key, err := buildTypeExpr(expr.key, resolve)
+		if err != nil {
+			return nil, err
+		}
+		if !key.Comparable() {
+			return nil, fmt.Errorf("invalid map key type %s", key)
+		}
+		elem, err := buildTypeExpr(expr.elem, resolve)
+		if err != nil {
+			return nil, err
+		}
+		return reflect.MapOf(key, elem), nil
+
+	case typeExprFunc:
+		in := make([]reflect.Type, 0, len(expr.in))
+		for _, param := range expr.in {
+			typ, err := buildTypeExpr(param, resolve)
+			if err != nil {
+				return nil, err
+			}
+			in = append(in, typ)
+		}
+		out := make([]reflect.Type, 0, len(expr.out))
+		for _, param := range expr.out {
+			typ, err := buildTypeExpr(param, resolve)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, typ)
+		}
+		return reflect.FuncOf(in, out, expr.variadic), nil
+	}
+	return nil, ErrNotSupport
+}
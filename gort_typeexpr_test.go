@@ -0,0 +1,39 @@
+package gort_test
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindTypeExpr(t *testing.T) {
+	rt := newRT(t)
+	tests := []struct {
+		expr string
+		want reflect.Type
+	}{
+		{"[]int", reflect.TypeOf([]int(nil))},
+		{"[3]string", reflect.TypeOf([3]string{})},
+		{"map[string]*" + testPkg + ".point", reflect.TypeOf(map[string]*point(nil))},
+		{"func(int) (string, error)", reflect.TypeOf(func(int) (string, error) { return "", nil })},
+		{"func(string, ...interface {})", reflect.TypeOf(func(string, ...interface{}) {})},
+		{"chan<- []" + testPkg + ".point", reflect.TypeOf(make(chan<- []point))},
+		{"map[int][]map[string]int", reflect.TypeOf(map[int][]map[string]int(nil))},
+	}
+	for _, test := range tests {
+		typ, err := rt.FindType(test.expr)
+		if err != nil {
+			t.Errorf("find type %s err %s", test.expr, err)
+			continue
+		}
+		if typ != test.want {
+			t.Errorf("find type %s got %s, want %s", test.expr, typ, test.want)
+		}
+	}
+
+	if _, err := rt.FindType("[]nosuch.T"); err == nil {
+		t.Errorf("find type of unknown element succeeded")
+	}
+	if _, err := rt.FindType("map[string"); err == nil {
+		t.Errorf("find type of malformed expression succeeded")
+	}
+}
@@ -2,8 +2,11 @@ package gort
 
 import (
 	"debug/dwarf"
+	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"unsafe"
 
 	"github.com/go-delve/delve/pkg/dwarf/godwarf"
@@ -11,11 +14,11 @@ import (
 )
 
 func (d *DwarfRT) ForeachType(f func(name string)) error {
-	if err := d.check(); err != nil {
+	if err := d.rlock(); err != nil {
 		return err
 	}
-
 	types, err := d.bi.Types()
+	d.mu.RUnlock()
 	if err != nil {
 		return err
 	}
@@ -25,36 +28,108 @@ func (d *DwarfRT) ForeachType(f func(name string)) error {
 	return nil
 }
 
+// FindType returns the runtime type name, or a type synthesized from DWARF
+// when the binary has none, see IsSynthesized. Named types other than structs
+// can not be synthesized, as the result would be their underlying type.
 func (d *DwarfRT) FindType(name string) (reflect.Type, error) {
-	if err := d.check(); err != nil {
+	if err := d.rlock(); err != nil {
 		return nil, err
 	}
+	defer d.mu.RUnlock()
 
-	dwarfType, err := findType(d.bi, name)
+	typ, err := d.lookupType(name)
 	if err != nil {
 		return nil, err
 	}
+	_, local := d.splitImage(name)
+	if err := checkUnderlying(local, typ); err != nil {
+		return nil, err
+	}
+	return typ, nil
+}
 
-	typeAddr, err := d.dwarfToRuntimeType(dwarfType, name)
-	if err != nil {
+func (d *DwarfRT) lookupType(name string) (reflect.Type, error) {
+	img, name := d.splitImage(name)
+
+	d.dwarfMu.Lock()
+	defer d.dwarfMu.Unlock()
+
+	return d.resolveTypeNameIn(img, name)
+}
+
+// resolveTypeName resolves name through its DWARF entry, falling back to
+// composing type expressions such as "[]main.T" from their named parts.
+// d.dwarfMu must be held.
+func (d *DwarfRT) resolveTypeName(name string) (reflect.Type, error) {
+	return d.resolveTypeNameIn(nil, name)
+}
+
+// resolveTypeNameIn is resolveTypeName preferring the types of img, any image when nil.
+// d.dwarfMu must be held.
+func (d *DwarfRT) resolveTypeNameIn(img *proc.Image, name string) (reflect.Type, error) {
+	dwarfType, err := d.findDwarfType(img, name)
+	if err == nil {
+		return d.resolveDwarfType(dwarfType, name)
+	}
+	if errors.Is(err, ErrAmbiguous) {
 		return nil, err
 	}
+	if normalized := normalizeTypeName(name); normalized != name {
+		if dwarfType, nerr := d.findDwarfType(img, normalized); nerr == nil {
+			return d.resolveDwarfType(dwarfType, normalized)
+		}
+	}
 
-	typ := reflect.TypeOf(*(*interface{})(unsafe.Pointer(&typeAddr)))
+	expr, perr := parseTypeExpr(name)
+	if perr != nil || expr.kind == typeExprNamed {
+		if builtin, ok := builtinTypes[name]; ok {
+			return builtin, nil
+		}
+		return nil, err
+	}
+	return buildTypeExpr(expr, func(name string) (reflect.Type, error) {
+		typ, err := d.resolveTypeNameIn(img, name)
+		if err != nil && img != nil && errors.Is(err, ErrNotFound) {
+			return d.resolveTypeNameIn(nil, name)
+		}
+		return typ, err
+	})
+}
+
+// resolveDwarfType returns the runtime type of dwarfType, or a layout identical
+// type synthesized from DWARF when the binary has no runtime type for it.
+func (d *DwarfRT) resolveDwarfType(dwarfType godwarf.Type, name string) (reflect.Type, error) {
+	typeAddr, err := d.dwarfToRuntimeType(dwarfType, name)
+	if err == nil {
+		return runtimeTypeAt(typeAddr), nil
+	}
+
+	typ, serr := d.synthesizeType(dwarfType, make(map[dwarf.Offset]bool))
+	if serr != nil {
+		return nil, fmt.Errorf("%s, synthesize err:%s", err, serr)
+	}
 	return typ, nil
 }
 
+func runtimeTypeAt(typeAddr uint64) reflect.Type {
+	return reflect.TypeOf(*(*interface{})(unsafe.Pointer(&typeAddr)))
+}
+
 func (d *DwarfRT) findImageType(img *proc.Image, name string) uint64 {
-	if d.imageCacheTypes == nil {
-		d.imageCacheTypes = make(map[*proc.Image]map[string]uint64)
+	snap := d.snap
+	snap.imageTypesMu.Lock()
+	defer snap.imageTypesMu.Unlock()
+
+	if snap.imageCacheTypes == nil {
+		snap.imageCacheTypes = make(map[*proc.Image]map[string]uint64)
 	}
-	cache, ok := d.imageCacheTypes[img]
+	cache, ok := snap.imageCacheTypes[img]
 	if !ok {
 		cache = make(map[string]uint64)
-		d.imageCacheTypes[img] = cache
+		snap.imageCacheTypes[img] = cache
 
 		reader := img.DwarfReader()
-		md := imageToModuleData(d.bi, img, d.mds)
+		md := imageToModuleData(d.bi, img, snap.mds)
 		if md == nil {
 			return 0
 		}
@@ -93,7 +168,7 @@ func (d *DwarfRT) findImageType(img *proc.Image, name string) uint64 {
 
 func (d *DwarfRT) dwarfToRuntimeType(typ godwarf.Type, name string) (typeAddr uint64, err error) {
 	bi := d.bi
-	mds := d.mds
+	mds := d.snap.mds
 
 	if typ.Common().Index >= len(bi.Images) {
 		return 0, fmt.Errorf("could not find image for type %s", name)
@@ -134,3 +209,126 @@ func (d *DwarfRT) dwarfToRuntimeType(typ godwarf.Type, name string) (typeAddr ui
 	}
 	return typeAddr, nil
 }
+
+// Implementers returns the concrete named types in the binary and its loaded
+// images that implement the interface ifaceName, sorted by name. A type is
+// returned as T when its value method set implements the interface and as *T
+// when only its pointer method set does. Candidates come from DWARF and from
+// the itabs known to the runtime, which also covers types that are only ever
+// converted to interfaces.
+func (d *DwarfRT) Implementers(ifaceName string) ([]reflect.Type, error) {
+	if err := d.rlock(); err != nil {
+		return nil, err
+	}
+	defer d.mu.RUnlock()
+
+	iface, err := d.lookupType(ifaceName)
+	if err != nil {
+		return nil, err
+	}
+	if iface.Kind() != reflect.Interface {
+		return nil, fmt.Errorf("type %s is not an interface", ifaceName)
+	}
+
+	names, err := d.bi.Types()
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[reflect.Type]bool)
+	add := func(typ reflect.Type) {
+		if typ.Kind() == reflect.Ptr && typ.Elem().Name() != "" && typ.Elem().Implements(iface) {
+			typ = typ.Elem()
+		}
+		if typ.Name() == "" && (typ.Kind() != reflect.Ptr || typ.Elem().Name() == "") {
+			return
+		}
+		if typ.Kind() != reflect.Interface && typ.Implements(iface) {
+			found[typ] = true
+		}
+	}
+
+	d.dwarfMu.Lock()
+	for _, name := range names {
+		if strings.HasPrefix(name, "go.shape.") {
+			continue
+		}
+		expr, err := parseTypeExpr(name)
+		if err != nil || expr.kind != typeExprNamed {
+			continue
+		}
+		typ, ok := d.runtimeTypeByName(name)
+		if !ok {
+			continue
+		}
+		add(typ)
+		if ptr, ok := d.runtimeTypeByName("*" + name); ok {
+			add(ptr)
+		}
+	}
+	d.dwarfMu.Unlock()
+
+	for _, typ := range d.itabTypes() {
+		add(typ)
+	}
+
+	result := make([]reflect.Type, 0, len(found))
+	for typ := range found {
+		result = append(result, typ)
+	}
+	sort.Slice(result, func(i, j int) bool { return implementerName(result[i]) < implementerName(result[j]) })
+	return result, nil
+}
+
+// runtimeTypeByName returns the runtime type descriptor of name without
+// synthesizing one. d.dwarfMu must be held.
+func (d *DwarfRT) runtimeTypeByName(name string) (reflect.Type, bool) {
+	dwarfType, err := findType(d.bi, name)
+	if err != nil {
+		return nil, false
+	}
+	typeAddr, err := d.dwarfToRuntimeType(dwarfType, name)
+	if err != nil {
+		return nil, false
+	}
+	return runtimeTypeAt(typeAddr), true
+}
+
+// itabTypes returns the concrete types of the itabs in runtime.itabTable,
+// the runtime adds the itabs of every module to it at start up and when a
+// plugin is loaded, and the itabs built by interface conversions at run time.
+func (d *DwarfRT) itabTypes() []reflect.Type {
+	global, ok := d.loadGlobals()["runtime.itabTable"]
+	if !ok || !global.CanAddr() {
+		return nil
+	}
+	// mirrors runtime.itabTableType{size, count uintptr; entries [size]*itab}
+	table := *(*unsafe.Pointer)(unsafe.Pointer(global.UnsafeAddr()))
+	if table == nil {
+		return nil
+	}
+	size := *(*uintptr)(table)
+	entries := unsafe.Pointer(uintptr(table) + 2*unsafe.Sizeof(uintptr(0)))
+
+	var types []reflect.Type
+	for i := uintptr(0); i < size; i++ {
+		// mirrors internal/abi.ITab{Inter *InterfaceType; Type *Type; ...}
+		itab := *(*unsafe.Pointer)(unsafe.Pointer(uintptr(entries) + i*unsafe.Sizeof(uintptr(0))))
+		if itab == nil {
+			continue
+		}
+		typ := *(*unsafe.Pointer)(unsafe.Pointer(uintptr(itab) + unsafe.Sizeof(uintptr(0))))
+		if typ == nil {
+			continue
+		}
+		types = append(types, runtimeTypeAt(uint64(uintptr(typ))))
+	}
+	return types
+}
+
+func implementerName(typ reflect.Type) string {
+	if typ.Kind() == reflect.Ptr {
+		return "*" + fullTypeName(typ.Elem())
+	}
+	return fullTypeName(typ)
+}
@@ -0,0 +1,401 @@
+package gort
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+const (
+	watchMaxDepth   = 8       // pointers, maps and interfaces followed by a copy, deeper ones are compared by address
+	watchMaxValues  = 1 << 16 // values copied per poll, the rest are compared by address or length
+	watchMaxChanges = 256     // changes reported per poll
+)
+
+// Change is a difference between two copies of a watched value. Path locates
+// it in the value, e.g. `.timeouts.read`, `[2]` or `["key"]`, Old is invalid
+// for added elements and New for removed ones. Maps that are not copied are
+// reported with their addresses when replaced and at `len(.limits)` with
+// their lengths when resized.
+type Change struct {
+	Path string
+	Old  reflect.Value
+	New  reflect.Value
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Path, formatChangeValue(c.Old), formatChangeValue(c.New))
+}
+
+func formatChangeValue(v reflect.Value) string {
+	if !v.IsValid() {
+		return "<none>"
+	}
+	return fmt.Sprintf("%+v", v)
+}
+
+// WatchOptions refines a Watch, the zero value compares maps by address and length.
+type WatchOptions struct {
+	// CopyMaps copies the entries of maps within the watch limits, so changes
+	// are reported per key. Maps must not be written while they are copied,
+	// the runtime aborts the process on concurrent map iteration and write.
+	CopyMaps bool
+}
+
+// WatchHandle is a running watch, Stop cancels it.
+type WatchHandle struct {
+	Path string
+
+	stop chan struct{}
+	once sync.Once
+	mu   sync.Mutex
+	err  error
+}
+
+// Watch polls the global or the field of a global at path, e.g. "main.cfg.timeouts",
+// every interval and calls f with deep copies of the previous and the current
+// value and their differences when it changed. The path is resolved again on
+// every poll, so replaced pointers on the way are followed. Copies follow
+// pointers and interfaces up to a fixed depth and value count, values beyond
+// are compared by address or length. Maps are not iterated, their copies are
+// empty and they are compared by address and length, a resized map is
+// reported as len(path), see WatchWithOptions. f runs on the watching
+// goroutine, a panic in it or in a later copy stops the watch and is returned
+// by Err, a panic in the first copy is returned by Watch.
+func (d *DwarfRT) Watch(path string, interval time.Duration, f func(old, new reflect.Value, diff []Change)) (*WatchHandle, error) {
+	return d.WatchWithOptions(path, interval, nil, f)
+}
+
+// WatchWithOptions is Watch refined by opts, nil is the zero WatchOptions.
+func (d *DwarfRT) WatchWithOptions(path string, interval time.Duration, opts *WatchOptions, f func(old, new reflect.Value, diff []Change)) (*WatchHandle, error) {
+	if opts == nil {
+		opts = &WatchOptions{}
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("watch %s: invalid interval %s", path, interval)
+	}
+	v, err := d.globalPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("watch %s: %w", path, err)
+	}
+	old, err := firstCopy(v, opts)
+	if err != nil {
+		return nil, fmt.Errorf("watch %s: %w", path, err)
+	}
+	w := &WatchHandle{Path: path, stop: make(chan struct{})}
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				w.mu.Lock()
+				w.err = fmt.Errorf("watch %s: panic: %v\n%s", path, r, debug.Stack())
+				w.mu.Unlock()
+				w.Stop()
+			}
+		}()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+			var cur *watchCopy
+			if v, err := d.globalPath(path); err == nil {
+				cur = copyWatched(v, opts)
+			} else {
+				cur = &watchCopy{}
+			}
+			diff := diffWatched(old, cur)
+			if len(diff) == 0 {
+				continue
+			}
+			select {
+			case <-w.stop:
+				return
+			default:
+			}
+			f(old.v, cur.v, diff)
+			old = cur
+		}
+	}()
+	return w, nil
+}
+
+// Stop cancels the watch, a call of f already running is not waited for.
+func (w *WatchHandle) Stop() {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+}
+
+// Err returns the panic that stopped the watch, or nil.
+func (w *WatchHandle) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// firstCopy is copyWatched returning a panic as an error, it runs on the goroutine calling Watch.
+func firstCopy(v reflect.Value, opts *WatchOptions) (c *watchCopy, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return copyWatched(v, opts), nil
+}
+
+// watchCopy is a deep copy of a watched value.
+type watchCopy struct {
+	v    reflect.Value
+	maps map[uintptr]watchedMap // by the address of the maps in v
+}
+
+// watchedMap is a map of the watched value at the time of the copy, maps not
+// copied are replaced by an empty one.
+type watchedMap struct {
+	addr   unsafe.Pointer
+	len    int
+	copied bool
+}
+
+// copyWatched deep copies v within the watch limits.
+func copyWatched(v reflect.Value, opts *WatchOptions) *watchCopy {
+	c := &watchCopier{
+		budget:   watchMaxValues,
+		copyMaps: opts.CopyMaps,
+		seen:     make(map[savedRef]reflect.Value),
+		maps:     make(map[uintptr]watchedMap),
+	}
+	return &watchCopy{v: c.copy(v, 0), maps: c.maps}
+}
+
+type watchCopier struct {
+	budget   int
+	copyMaps bool
+	seen     map[savedRef]reflect.Value // copies of the pointers followed, keeps cycles finite
+	maps     map[uintptr]watchedMap
+}
+
+func (c *watchCopier) copy(v reflect.Value, depth int) reflect.Value {
+	c.budget--
+	typ := v.Type()
+	dst := reflect.New(typ).Elem()
+	if v.CanAddr() {
+		v = writable(v)
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || depth >= watchMaxDepth || c.budget <= 0 {
+			dst.Set(v)
+			break
+		}
+		// pointers of different types may share an address, e.g. a struct and its first field
+		ref := savedRef{v.Pointer(), typ}
+		if p, ok := c.seen[ref]; ok {
+			dst.Set(p)
+			break
+		}
+		p := reflect.New(typ.Elem())
+		c.seen[ref] = p
+		p.Elem().Set(c.copy(v.Elem(), depth+1))
+		dst.Set(p)
+	case reflect.Interface:
+		if v.IsNil() || depth >= watchMaxDepth || c.budget <= 0 {
+			dst.Set(v)
+			break
+		}
+		dst.Set(c.copy(v.Elem(), depth+1))
+	case reflect.Struct:
+		if !v.CanAddr() {
+			// fields of unaddressable structs are read only
+			tmp := reflect.New(typ).Elem()
+			tmp.Set(v)
+			v = tmp
+		}
+		for i := 0; i < v.NumField(); i++ {
+			writable(dst.Field(i)).Set(c.copy(v.Field(i), depth))
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if c.budget <= 0 {
+				dst.Index(i).Set(v.Index(i))
+				continue
+			}
+			dst.Index(i).Set(c.copy(v.Index(i), depth))
+		}
+	case reflect.Slice:
+		if v.IsNil() || c.budget < v.Len() {
+			dst.Set(v)
+			break
+		}
+		dst.Set(reflect.MakeSlice(typ, v.Len(), v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			dst.Index(i).Set(c.copy(v.Index(i), depth))
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			break
+		}
+		m := watchedMap{addr: v.UnsafePointer(), len: v.Len()}
+		if !c.copyMaps || depth >= watchMaxDepth || c.budget < m.len {
+			dst.Set(reflect.MakeMap(typ))
+		} else {
+			m.copied = true
+			dst.Set(reflect.MakeMapWithSize(typ, m.len))
+			iter := v.MapRange()
+			for iter.Next() {
+				dst.SetMapIndex(iter.Key(), c.copy(iter.Value(), depth+1))
+			}
+		}
+		c.maps[dst.Pointer()] = m
+	default:
+		dst.Set(v)
+	}
+	return dst
+}
+
+// diffWatched returns the differences between the copies old and new.
+func diffWatched(old, new *watchCopy) []Change {
+	w := &watchDiffer{old: old, new: new, seen: make(map[diffRef]bool)}
+	w.diff(old.v, new.v, "")
+	return w.changes
+}
+
+type watchDiffer struct {
+	old, new *watchCopy
+	changes  []Change
+	seen     map[diffRef]bool // pointer pairs compared, keeps cycles finite
+}
+
+type diffRef struct {
+	old, new uintptr
+	typ      reflect.Type
+}
+
+func (w *watchDiffer) diff(old, new reflect.Value, path string) {
+	if len(w.changes) >= watchMaxChanges {
+		return
+	}
+	if !old.IsValid() || !new.IsValid() || old.Type() != new.Type() {
+		if old.IsValid() || new.IsValid() {
+			w.changes = append(w.changes, Change{Path: path, Old: old, New: new})
+		}
+		return
+	}
+	changed := false
+	switch old.Kind() {
+	case reflect.Bool:
+		changed = old.Bool() != new.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		changed = old.Int() != new.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		changed = old.Uint() != new.Uint()
+	case reflect.Float32, reflect.Float64:
+		changed = math.Float64bits(old.Float()) != math.Float64bits(new.Float())
+	case reflect.Complex64, reflect.Complex128:
+		o, n := old.Complex(), new.Complex()
+		changed = math.Float64bits(real(o)) != math.Float64bits(real(n)) || math.Float64bits(imag(o)) != math.Float64bits(imag(n))
+	case reflect.String:
+		changed = old.String() != new.String()
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		changed = old.Pointer() != new.Pointer()
+	case reflect.Ptr:
+		switch {
+		case old.IsNil() || new.IsNil():
+			changed = old.IsNil() != new.IsNil()
+		case old.Pointer() == new.Pointer() || w.seen[diffRef{old.Pointer(), new.Pointer(), old.Type()}]:
+			// not copied beyond the watch limits, or already compared
+		default:
+			w.seen[diffRef{old.Pointer(), new.Pointer(), old.Type()}] = true
+			w.diff(old.Elem(), new.Elem(), path)
+		}
+	case reflect.Interface:
+		if old.IsNil() || new.IsNil() {
+			changed = old.IsNil() != new.IsNil()
+			break
+		}
+		w.diff(old.Elem(), new.Elem(), path)
+	case reflect.Struct:
+		for i := 0; i < old.NumField(); i++ {
+			w.diff(writableField(old, i), writableField(new, i), path+"."+fieldName(old.Type().Field(i)))
+		}
+	case reflect.Array, reflect.Slice:
+		if old.Kind() == reflect.Slice && (old.IsNil() || new.IsNil()) {
+			changed = old.IsNil() != new.IsNil()
+			break
+		}
+		if old.Kind() == reflect.Slice && old.Pointer() == new.Pointer() && old.Len() == new.Len() {
+			// not copied, beyond the watch limits
+			break
+		}
+		n := old.Len()
+		if new.Len() > n {
+			n = new.Len()
+		}
+		for i := 0; i < n; i++ {
+			var o, nv reflect.Value
+			if i < old.Len() {
+				o = old.Index(i)
+			}
+			if i < new.Len() {
+				nv = new.Index(i)
+			}
+			w.diff(o, nv, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Map:
+		o, n := watchedMapOf(w.old, old), watchedMapOf(w.new, new)
+		switch {
+		case o.copied && n.copied:
+			w.diffEntries(old, new, path)
+		case o.addr != n.addr:
+			w.changes = append(w.changes, Change{Path: path, Old: reflect.ValueOf(o.addr), New: reflect.ValueOf(n.addr)})
+		case o.len != n.len:
+			w.changes = append(w.changes, Change{Path: "len(" + path + ")", Old: reflect.ValueOf(o.len), New: reflect.ValueOf(n.len)})
+		}
+	}
+	if changed {
+		w.changes = append(w.changes, Change{Path: path, Old: old, New: new})
+	}
+}
+
+// diffEntries compares the entries of the copied maps old and new.
+func (w *watchDiffer) diffEntries(old, new reflect.Value, path string) {
+	keys := append(old.MapKeys(), new.MapKeys()...)
+	sort.Slice(keys, func(i, j int) bool { return keyPath(keys[i]) < keyPath(keys[j]) })
+	done := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		p := path + "[" + keyPath(key) + "]"
+		if done[p] {
+			continue
+		}
+		done[p] = true
+		w.diff(old.MapIndex(key), new.MapIndex(key), p)
+	}
+}
+
+// watchedMapOf returns the map m of the copy c as it was copied. Maps shared
+// with the watched value beyond the watch limits are read as they are now.
+func watchedMapOf(c *watchCopy, m reflect.Value) watchedMap {
+	if m.IsNil() {
+		return watchedMap{}
+	}
+	if wm, ok := c.maps[m.Pointer()]; ok {
+		return wm
+	}
+	return watchedMap{addr: m.UnsafePointer(), len: m.Len()}
+}
+
+// writableField returns field i of the struct v, settable when v is addressable.
+func writableField(v reflect.Value, i int) reflect.Value {
+	if v.CanAddr() {
+		return writable(v.Field(i))
+	}
+	return v.Field(i)
+}
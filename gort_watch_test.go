@@ -0,0 +1,119 @@
+package gort_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lsg2020/gort"
+	"github.com/lsg2020/gort/internal/testdwarf"
+)
+
+type watchTimeouts struct {
+	read, write time.Duration
+}
+
+type watchConfig struct {
+	name     string
+	timeouts watchTimeouts
+	limits   map[string]int
+}
+
+var (
+	watchCfg  = &watchConfig{name: "cfg", timeouts: watchTimeouts{read: time.Second}, limits: map[string]int{"a": 1}}
+	watchIdle = &watchConfig{}
+)
+
+// watchOne starts a watch of path, calls change and returns the first
+// reported differences.
+func watchOne(t *testing.T, rt *gort.DwarfRT, path string, opts *gort.WatchOptions, change func()) []gort.Change {
+	t.Helper()
+	if testdwarf.RaceEnabled {
+		t.Skip("watches poll without synchronization, the race detector reports them")
+	}
+	diffs := make(chan []gort.Change, 1)
+	w, err := rt.WatchWithOptions(path, time.Millisecond, opts, func(old, new reflect.Value, diff []gort.Change) {
+		select {
+		case diffs <- diff:
+		default:
+		}
+	})
+	if err != nil {
+		t.Fatalf("watch %s err %s", path, err)
+	}
+	defer w.Stop()
+	change()
+	select {
+	case diff := <-diffs:
+		return diff
+	case <-time.After(5 * time.Second):
+		t.Fatalf("watch %s reported no change", path)
+	}
+	return nil
+}
+
+func TestWatch(t *testing.T) {
+	rt := newRT(t)
+	if watchCfg.name != "cfg" || watchIdle == nil {
+		t.Fatalf("fixtures changed")
+	}
+
+	diff := watchOne(t, rt, testPkg+".watchCfg.timeouts", nil, func() {
+		watchCfg.timeouts.read = 3 * time.Second
+	})
+	if len(diff) != 1 || diff[0].Path != ".read" || diff[0].Old.Interface() != time.Second || diff[0].New.Interface() != 3*time.Second {
+		t.Errorf("watch reported %v", diff)
+	}
+
+	// maps are compared by length unless they are copied
+	diff = watchOne(t, rt, testPkg+".watchCfg", nil, func() {
+		watchCfg.limits["b"] = 2
+	})
+	if len(diff) != 1 || diff[0].Path != "len(.limits)" {
+		t.Errorf("watch of a map reported %v", diff)
+	}
+	diff = watchOne(t, rt, testPkg+".watchCfg", &gort.WatchOptions{CopyMaps: true}, func() {
+		watchCfg.limits["b"] = 3
+	})
+	if len(diff) != 1 || diff[0].Path != `.limits["b"]` || diff[0].New.Int() != 3 {
+		t.Errorf("watch of a copied map reported %v", diff)
+	}
+
+	// a panic in the callback stops the watch
+	w, err := rt.Watch(testPkg+".watchCfg.name", time.Millisecond, func(old, new reflect.Value, diff []gort.Change) {
+		panic("callback")
+	})
+	if err != nil {
+		t.Fatalf("watch err %s", err)
+	}
+	defer w.Stop()
+	watchCfg.name = "renamed"
+	deadline := time.Now().Add(5 * time.Second)
+	for w.Err() == nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if err := w.Err(); err == nil || !strings.Contains(err.Error(), "panic: callback") {
+		t.Errorf("watch err %v after a panic in the callback", err)
+	}
+}
+
+func TestWatchErrors(t *testing.T) {
+	rt := newRT(t)
+	if _, err := rt.Watch(testPkg+".missing", time.Second, func(old, new reflect.Value, diff []gort.Change) {}); err == nil {
+		t.Errorf("watch of a missing global succeeded")
+	}
+	if _, err := rt.Watch(testPkg+".watchCfg", 0, func(old, new reflect.Value, diff []gort.Change) {}); err == nil {
+		t.Errorf("watch with a zero interval succeeded")
+	}
+
+	w, err := rt.Watch(testPkg+".watchIdle", time.Hour, func(old, new reflect.Value, diff []gort.Change) {})
+	if err != nil {
+		t.Fatalf("watch err %s", err)
+	}
+	w.Stop()
+	w.Stop()
+	if err := w.Err(); err != nil {
+		t.Errorf("stopped watch err %s", err)
+	}
+}
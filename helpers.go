@@ -4,6 +4,7 @@ import (
 	"debug/dwarf"
 	"fmt"
 	"reflect"
+	"strings"
 	"unsafe"
 
 	"github.com/go-delve/delve/pkg/dwarf/godwarf"
@@ -42,6 +43,12 @@ func dwarfTypeName(dtyp dwarf.Type) string {
 	switch dtyp := dtyp.(type) {
 	case *dwarf.StructType:
 		return dtyp.StructName
+	case *dwarf.TypedefType:
+		// type parameters of shape functions, e.g. ".param0" for []go.shape.int
+		if strings.HasPrefix(dtyp.Name, ".param") {
+			return dwarfTypeName(dtyp.Type)
+		}
+		return dtyp.Name
 	default:
 		name := dtyp.Common().Name
 		if name != "" {
@@ -51,11 +58,13 @@ func dwarfTypeName(dtyp dwarf.Type) string {
 	}
 }
 
-func entryType(data *dwarf.Data, entry *dwarf.Entry) (dwarf.Type, error) {
+func (d *DwarfRT) entryType(data *dwarf.Data, entry *dwarf.Entry) (dwarf.Type, error) {
 	off, ok := entry.Val(dwarf.AttrType).(dwarf.Offset)
 	if !ok {
 		return nil, fmt.Errorf("unable to find type offset for entry")
 	}
+	d.dwarfMu.Lock()
+	defer d.dwarfMu.Unlock()
 	return data.Type(off)
 }
 
@@ -63,7 +72,7 @@ func entryAddress(p uintptr, l int) []byte {
 	return *(*[]byte)(unsafe.Pointer(&reflect.SliceHeader{Data: p, Len: l, Cap: l}))
 }
 
-type Func struct {
+type funcValue struct {
 	codePtr uintptr
 }
 
@@ -79,8 +88,118 @@ func CreateFuncForCodePtr(ftyp reflect.Type, codePtr uint64) reflect.Value {
 	// work because it gives the code pointer rather than the function value
 	// pointer. The function value is a struct that starts with its code
 	// pointer, so we can swap out the code pointer with our desired value.
-	funcValuePtr := reflect.ValueOf(newFuncVal).FieldByName("ptr").Pointer()
-	funcPtr := (*Func)(unsafe.Pointer(funcValuePtr))
+	funcPtr := (*funcValue)(reflect.ValueOf(newFuncVal).FieldByName("ptr").UnsafePointer())
 	funcPtr.codePtr = uintptr(codePtr)
 	return newFuncVal
 }
+
+// splitPackage splits a qualified name such as "github.com/x/y.T[a/b.C]"
+// into its package path and the rest, ignoring type arguments.
+func splitPackage(name string) (string, string) {
+	depth := 0
+	lastSlash := -1
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '[', '(':
+			depth++
+		case ']', ')':
+			depth--
+		case '/':
+			if depth == 0 {
+				lastSlash = i
+			}
+		}
+	}
+	depth = 0
+	for i := lastSlash + 1; i < len(name); i++ {
+		switch name[i] {
+		case '[', '(':
+			depth++
+		case ']', ')':
+			depth--
+		case '.':
+			if depth == 0 {
+				return name[:i], name[i+1:]
+			}
+		}
+	}
+	return "", name
+}
+
+// funcDecl returns the declaring file and line of f from its DWARF entry.
+func (d *DwarfRT) funcDecl(f *proc.Function) (string, int) {
+	rOffset := reflect.ValueOf(f).Elem().FieldByName("offset")
+	if !rOffset.IsValid() {
+		return "", 0
+	}
+	image, dwarfData, cuEntry := compileUnitOf(reflect.ValueOf(f).Elem().FieldByName("cu"))
+	if image == nil {
+		return "", 0
+	}
+
+	reader := image.DwarfReader()
+	reader.Seek(dwarf.Offset(rOffset.Uint()))
+	entry, err := reader.Next()
+	if err != nil || entry == nil {
+		return "", 0
+	}
+	line, _ := entry.Val(dwarf.AttrDeclLine).(int64)
+	fileIndex, ok := entry.Val(dwarf.AttrDeclFile).(int64)
+	if !ok {
+		return "", int(line)
+	}
+	files := d.cuFiles(dwarfData, cuEntry)
+	if fileIndex < 0 || int(fileIndex) >= len(files) || files[fileIndex] == nil {
+		return "", int(line)
+	}
+	return files[fileIndex].Name, int(line)
+}
+
+// compileUnitOf returns the image, DWARF data and entry of the delve
+// *compileUnit rCU, image is nil when they are not available.
+func compileUnitOf(rCU reflect.Value) (*proc.Image, *dwarf.Data, *dwarf.Entry) {
+	if !rCU.IsValid() || rCU.IsNil() {
+		return nil, nil, nil
+	}
+	rEntry := rCU.Elem().FieldByName("entry")
+	rImage := rCU.Elem().FieldByName("image")
+	if !rEntry.IsValid() || !rImage.IsValid() || rImage.IsNil() {
+		return nil, nil, nil
+	}
+	rDwarf := rImage.Elem().FieldByName("dwarf")
+	if !rDwarf.IsValid() {
+		return nil, nil, nil
+	}
+	image := (*proc.Image)(unsafe.Pointer(rImage.Pointer()))
+	dwarfData := (*dwarf.Data)(unsafe.Pointer(rDwarf.Pointer()))
+	cuEntry := (*dwarf.Entry)(unsafe.Pointer(rEntry.Pointer()))
+	return image, dwarfData, cuEntry
+}
+
+// cuFiles returns the file table of the compile unit cu, cached per snapshot.
+func (d *DwarfRT) cuFiles(data *dwarf.Data, cu *dwarf.Entry) []*dwarf.LineFile {
+	snap := d.snap
+	snap.cuFilesMu.Lock()
+	defer snap.cuFilesMu.Unlock()
+
+	if files, ok := snap.cuFiles[cu]; ok {
+		return files
+	}
+	if snap.cuFiles == nil {
+		snap.cuFiles = make(map[*dwarf.Entry][]*dwarf.LineFile)
+	}
+	var files []*dwarf.LineFile
+	if lr, err := data.LineReader(cu); err == nil && lr != nil {
+		files = lr.Files()
+	}
+	snap.cuFiles[cu] = files
+	return files
+}
+
+// addrPointer returns addr as a pointer. The bits are reinterpreted rather than
+// converted, checkptr, enabled by -race, rejects pointers made from integers
+// that point into the data of the binary or into the heap.
+func addrPointer(addr uint64) unsafe.Pointer {
+	p := uintptr(addr)
+	return *(*unsafe.Pointer)(unsafe.Pointer(&p))
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package testdwarf
+
+// RaceEnabled reports whether the tests run with the race detector.
+const RaceEnabled = true
@@ -0,0 +1,78 @@
+// Package testdwarf runs the tests of a package from a test binary with DWARF.
+// go test links its binaries without DWARF, gort reads its own executable.
+package testdwarf
+
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+const envRebuilt = "GORT_TESTDWARF_REBUILT"
+
+// Main runs the tests of m, from a copy of the test binary built with DWARF
+// when the running one has none. Call it from TestMain.
+func Main(m *testing.M) {
+	os.Exit(Run(m))
+}
+
+// Run is Main returning the exit code, for a TestMain cleaning up after the tests.
+func Run(m *testing.M) int {
+	if os.Getenv(envRebuilt) != "" || hasDWARF() {
+		return m.Run()
+	}
+	return rerun()
+}
+
+// rerun builds the test binary of the package in the working directory with
+// DWARF and runs it with the arguments of this one, returning its exit code.
+func rerun() int {
+	dir, err := os.MkdirTemp("", "testdwarf")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "testdwarf: %s\n", err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+
+	bin := filepath.Join(dir, "test")
+	args := []string{"test", "-c", "-o", bin}
+	if RaceEnabled {
+		args = append(args, "-race")
+	}
+	build := exec.Command("go", args...)
+	build.Stdout, build.Stderr = os.Stdout, os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "testdwarf: build test binary with DWARF: %s\n", err)
+		return 1
+	}
+
+	run := exec.Command(bin, os.Args[1:]...)
+	run.Stdout, run.Stderr = os.Stdout, os.Stderr
+	run.Env = append(os.Environ(), envRebuilt+"=1")
+	if err := run.Run(); err != nil {
+		if exit, ok := err.(*exec.ExitError); ok {
+			return exit.ExitCode()
+		}
+		fmt.Fprintf(os.Stderr, "testdwarf: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// hasDWARF reports whether the running executable has DWARF, or is no ELF file
+// whose DWARF could be checked.
+func hasDWARF() bool {
+	path, err := os.Executable()
+	if err != nil {
+		return true
+	}
+	f, err := elf.Open(path)
+	if err != nil {
+		return true
+	}
+	defer f.Close()
+	return f.Section(".debug_info") != nil || f.Section(".zdebug_info") != nil
+}
@@ -0,0 +1,11 @@
+// Command b is a plugin of the tests, it links package lib as the other one.
+package main
+
+import "github.com/lsg2020/gort/testdata/plugins/lib"
+
+// Name is defined by each plugin.
+var Name = "b"
+
+func Count() int { return lib.Count() }
+
+func main() {}
@@ -0,0 +1,17 @@
+// Package lib is linked into both test plugins and not into the tests.
+package lib
+
+// Item is defined by both plugins.
+type Item struct {
+	N int
+}
+
+// Items is defined by both plugins.
+var Items = []Item{{1}}
+
+// Count is defined by both plugins.
+//
+//go:noinline
+func Count() int {
+	return len(Items)
+}
@@ -0,0 +1,4 @@
+// Package dep is a sub package of the snapshot test globals.
+package dep
+
+var Depth = 1
@@ -0,0 +1,35 @@
+// Package snapshot holds the globals the snapshot test saves and restores.
+package snapshot
+
+import (
+	"sync"
+
+	"github.com/lsg2020/gort/testdata/snapshot/dep"
+)
+
+type Item struct {
+	Name string
+	Tags []string
+}
+
+var (
+	Limit   = 10
+	Items   = []*Item{{Name: "a", Tags: []string{"x"}}}
+	ByName  = map[string]*Item{"a": Items[0]}
+	Current = Items[0]
+	name    = "snapshot"
+
+	// globals that can not be copied
+	Mu     sync.Mutex
+	Notify = make(chan int)
+	Hook   = func() {}
+)
+
+// Name returns the unexported global name.
+func Name() string { return name }
+
+// SetName sets the unexported global name.
+func SetName(s string) { name = s }
+
+// Depth returns the global of the sub package.
+func Depth() int { return dep.Depth }